@@ -0,0 +1,351 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* batch.go - batched, atomic writes to the LevelDB backend.
+ *
+ * Writing a definition touches a couple dozen keys: the word itself, and
+ * a posting in each of the indexes. Most of those are newline delimited
+ * lists, which means a read-modify-write for each, so two writers at the
+ * same time can (and will) drop each other's postings.
+ *
+ * So, rather than writing as we go, we stage everything into a
+ * pendingWrite, and then flush that to LevelDB as a leveldb.Batch, while
+ * holding the database's write lock. Each flush is a single batch, so a
+ * WriteDefinition either all lands or none of it does.
+ *
+ * Deletes go the same way: records are dropped, and postings are pulled
+ * back out of their lists, as part of the same batch.
+ *
+ * The BulkLoader stages lots of definitions before flushing, so a posting
+ * list that gets hit a thousand times during a load is only read and
+ * written once per flush, which is the bulk of the speedup. That's one
+ * batch per `FlushEvery` definitions, though, not one for the whole load,
+ * so a load that fails part way leaves the flushes before it in place. */
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"pault.ag/go/dictd/dictd"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+/* How many deletes we put into a single leveldb.Batch when clearing out
+ * a whole namespace (see Reindex), which doesn't need to be atomic. */
+const maxBatchSize = 100000
+
+/* Writes that haven't made it to LevelDB yet. Keys are the full,
+ * namespaced LevelDB keys ("namespace\nkey"). */
+type pendingWrite struct {
+	records  map[string]string
+	deletes  map[string]bool
+	postings map[string][]string
+	removals map[string][]string
+	counters map[string]int
+}
+
+func newPendingWrite() *pendingWrite {
+	return &pendingWrite{
+		records:  map[string]string{},
+		deletes:  map[string]bool{},
+		postings: map[string][]string{},
+		removals: map[string][]string{},
+		counters: map[string]int{},
+	}
+}
+
+/* Set the single valued record `key` in `namespace` to `value`. */
+func (this *pendingWrite) put(namespace string, key string, value string) {
+	full := namespace + "\n" + key
+	delete(this.deletes, full)
+	this.records[full] = value
+}
+
+/* Remove the single valued record `key` in `namespace`. */
+func (this *pendingWrite) remove(namespace string, key string) {
+	full := namespace + "\n" + key
+	delete(this.records, full)
+	this.deletes[full] = true
+}
+
+/* Add `word` to the index list `key` in `namespace`. */
+func (this *pendingWrite) post(namespace string, key string, word string) {
+	full := namespace + "\n" + key
+	this.postings[full] = append(this.postings[full], word)
+}
+
+/* Take `word` back out of the index list `key` in `namespace`, including
+ * anything we've staged but not written yet. Removals are done before
+ * postings, so a later post puts it back. */
+func (this *pendingWrite) unpost(namespace string, key string, word string) {
+	full := namespace + "\n" + key
+	this.postings[full] = withoutWord(this.postings[full], word)
+	this.removals[full] = append(this.removals[full], word)
+}
+
+/* Add `delta` to the "meta" counter `name`. */
+func (this *pendingWrite) count(name string, delta int) {
+	this.counters[name] += delta
+}
+
+/* Get a "namespaced" key, as it will be once `pending` is flushed (or as
+ * it is now, if `pending` is nil). */
+func (this *LevelDBDatabase) lookup(pending *pendingWrite, namespace string, key string) (string, error) {
+	full := namespace + "\n" + key
+	if pending != nil {
+		if value, ok := pending.records[full]; ok {
+			return value, nil
+		}
+		if pending.deletes[full] {
+			return "", errors.New("Pending delete")
+		}
+	}
+	return this.get(namespace, key)
+}
+
+/* Write everything in `pending` out to LevelDB, merging postings into
+ * the lists that are already there. The caller has to hold the write
+ * lock. */
+func (this *LevelDBDatabase) flush(pending *pendingWrite) error {
+	batch := new(leveldb.Batch)
+
+	for key, value := range pending.records {
+		batch.Put([]byte(key), []byte(value))
+	}
+
+	for key := range pending.deletes {
+		batch.Delete([]byte(key))
+	}
+
+	lists := map[string]bool{}
+	for key := range pending.postings {
+		lists[key] = true
+	}
+	for key := range pending.removals {
+		lists[key] = true
+	}
+
+	for key := range lists {
+		values := []string{}
+		data, err := this.db.Get([]byte(key), nil)
+		if err == nil {
+			/* Values are newline delimed */
+			values = strings.Split(string(data), "\n")
+		} else if err != leveldb.ErrNotFound {
+			return err
+		}
+
+		for _, el := range pending.removals[key] {
+			values = withoutWord(values, el)
+		}
+		values = mergeList(values, pending.postings[key])
+
+		if len(values) == 0 {
+			batch.Delete([]byte(key))
+		} else {
+			batch.Put([]byte(key), []byte(strings.Join(values, "\n")))
+		}
+	}
+
+	for name, delta := range pending.counters {
+		value := strconv.Itoa(this.counter(name) + delta)
+		batch.Put([]byte("meta\n"+name), []byte(value))
+	}
+
+	return this.db.Write(batch, nil)
+}
+
+/* Append any of `words` that aren't already in `values`. */
+func mergeList(values []string, words []string) []string {
+	seen := map[string]bool{}
+	for _, el := range values {
+		seen[el] = true
+	}
+	for _, el := range words {
+		if !seen[el] {
+			seen[el] = true
+			values = append(values, el)
+		}
+	}
+	return values
+}
+
+/* Get `values`, without any copies of `word`. */
+func withoutWord(values []string, word string) []string {
+	ret := []string{}
+	for _, el := range values {
+		if el != word {
+			ret = append(ret, el)
+		}
+	}
+	return ret
+}
+
+/* BulkLoader writes lots of definitions into a LevelDBDatabase, holding
+ * the index postings in memory and flushing them every `FlushEvery`
+ * definitions. Remember to Flush when you're done. */
+type BulkLoader struct {
+	FlushEvery int
+
+	database *LevelDBDatabase
+	pending  *pendingWrite
+	staged   int
+}
+
+/* Create a new BulkLoader writing into the LevelDBDatabase `database`. */
+func NewBulkLoader(database *LevelDBDatabase) *BulkLoader {
+	return &BulkLoader{
+		FlushEvery: 10000,
+		database:   database,
+		pending:    newPendingWrite(),
+	}
+}
+
+/* Stage the word `word`, defined by `definition`, flushing if we've got
+ * enough staged. */
+func (this *BulkLoader) WriteDefinition(word string, definition string) error {
+	this.database.lock.Lock()
+	this.database.stageDefinition(this.pending, word, definition)
+	this.database.lock.Unlock()
+
+	this.staged++
+	if this.staged >= this.FlushEvery {
+		return this.Flush()
+	}
+	return nil
+}
+
+/* Write everything staged so far out to LevelDB. */
+func (this *BulkLoader) Flush() error {
+	this.database.lock.Lock()
+	defer this.database.lock.Unlock()
+
+	err := this.database.flush(this.pending)
+	this.pending = newPendingWrite()
+	this.staged = 0
+	return err
+}
+
+/* Stage the Definition `definition`, with its structured fields, flushing
+ * if we've got enough staged. */
+func (this *BulkLoader) WriteStructuredDefinition(definition *dictd.Definition) error {
+	this.database.lock.Lock()
+	this.database.stageStructuredDefinition(this.pending, definition)
+	this.database.lock.Unlock()
+
+	this.staged++
+	if this.staged >= this.FlushEvery {
+		return this.Flush()
+	}
+	return nil
+}
+
+/* Stage recording how often the word `word` is used (see WriteFrequency),
+ * flushing if we've got enough staged. */
+func (this *BulkLoader) WriteFrequency(word string, count int) error {
+	this.database.lock.Lock()
+	this.pending.put("frequency", this.database.key(word), strconv.Itoa(count))
+	this.database.lock.Unlock()
+
+	this.staged++
+	if this.staged >= this.FlushEvery {
+		return this.Flush()
+	}
+	return nil
+}
+
+/* Stage deleting the word `word` (see DeleteDefinition), if it's defined,
+ * flushing if we've got enough staged. */
+func (this *BulkLoader) DeleteDefinition(word string) error {
+	this.database.lock.Lock()
+	this.database.stageDeleteWord(this.pending, word)
+	this.database.lock.Unlock()
+
+	this.staged++
+	if this.staged >= this.FlushEvery {
+		return this.Flush()
+	}
+	return nil
+}
+
+/* Get every key in the namespace `namespace`. */
+func (this *LevelDBDatabase) keys(namespace string) ([]string, error) {
+	keys := []string{}
+	prefix := namespace + "\n"
+	iter := this.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	for iter.Next() {
+		keys = append(keys, string(iter.Key())[len(prefix):])
+	}
+	iter.Release()
+	return keys, iter.Error()
+}
+
+/* Delete every word in the database, going by the keys they're stored
+ * under rather than recomputing them, so it works even if the words went
+ * in with other key normalization settings than we have now. Everything
+ * is flushed once we're done, so the settings can be changed after.
+ * Frequencies go too, even for words that were never defined, and the
+ * deletion index is turned on for whatever goes in next. Returns how many
+ * keys were removed. */
+func (this *BulkLoader) DeleteAll() (int, error) {
+	keys, err := this.database.keys("")
+	if err != nil {
+		return 0, err
+	}
+	frequencies, err := this.database.keys("frequency")
+	if err != nil {
+		return 0, err
+	}
+
+	this.database.lock.Lock()
+	for _, key := range frequencies {
+		this.pending.remove("frequency", key)
+	}
+	this.database.lock.Unlock()
+
+	for _, key := range keys {
+		this.database.lock.Lock()
+		this.database.stageDelete(this.pending, key)
+		this.database.lock.Unlock()
+
+		this.staged++
+		if this.staged >= this.FlushEvery {
+			if err := this.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := this.Flush(); err != nil {
+		return 0, err
+	}
+
+	/* There's nothing left that went in without the deletion index, so
+	 * it can be trusted from here on, like a brand new db's. */
+	if this.database.empty() {
+		this.database.write("meta", "deletes", strconv.Itoa(maxIndexedDistance))
+	}
+	return len(keys), nil
+}
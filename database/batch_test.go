@@ -0,0 +1,282 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"pault.ag/go/dictd/dictd"
+)
+
+func openTestDatabase(t *testing.T) (*LevelDBDatabase, func()) {
+	path, err := ioutil.TempDir("", "dictd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewLevelDBDatabase(path, "test")
+	if err != nil {
+		os.RemoveAll(path)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.db.Close()
+		os.RemoveAll(path)
+	}
+}
+
+func TestMergeList(t *testing.T) {
+	values := mergeList([]string{"a", "b"}, []string{"b", "c", "c"})
+
+	if len(values) != 3 || values[2] != "c" {
+		t.Errorf("Bad list merge")
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db.WriteDefinition("graph"+strconv.Itoa(i), "A graph.")
+		}(i)
+	}
+	wg.Wait()
+
+	if len(db.matchFromIndex("trigram", "gra")) != 20 {
+		t.Errorf("Lost postings writing concurrently")
+	}
+}
+
+func TestBulkLoader(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	loader := NewBulkLoader(db)
+	loader.FlushEvery = 3
+	for i := 0; i < 10; i++ {
+		if err := loader.WriteDefinition("Graph"+strconv.Itoa(i), "A graph."); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := loader.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.matchFromIndex("trigram", "gra")) != 10 {
+		t.Errorf("Lost postings bulk loading")
+	}
+
+	defs := db.Define("test", "GRAPH3")
+	if len(defs) != 1 || defs[0].Word != "Graph3" {
+		t.Errorf("Bad bulk loaded definition")
+	}
+
+	if db.counter("fulltext-docs") != 10 {
+		t.Errorf("Bad document count")
+	}
+}
+
+func TestDeleteDefinition(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteDefinition("graph", "A diagram.")
+	db.WriteDefinition("graphic", "A picture.")
+
+	if err := db.DeleteDefinition("Graph"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.Define("test", "graph")) != 0 {
+		t.Errorf("Definition survived a delete")
+	}
+
+	postings := db.matchFromIndex("trigram", "gra")
+	if len(postings) != 1 || postings[0] != "graphic" {
+		t.Errorf("Stale trigram posting after a delete")
+	}
+
+	if db.counter("fulltext-docs") != 1 {
+		t.Errorf("Bad document count after a delete")
+	}
+
+	if err := db.DeleteDefinition("graph"); err == nil {
+		t.Errorf("Deleted a word that isn't there")
+	}
+}
+
+func TestUpdateDefinition(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteDefinition("graph", "A diagram.")
+	if err := db.UpdateDefinition("graph", "A network."); err != nil {
+		t.Fatal(err)
+	}
+
+	defs := db.Define("test", "graph")
+	if len(defs) != 1 || defs[0].Definition != "A network." {
+		t.Errorf("Definition didn't get updated")
+	}
+
+	if len(db.matchFromIndex("fulltext", "diagram")) != 0 {
+		t.Errorf("Stale full text posting after an update")
+	}
+
+	if db.counter("fulltext-docs") != 1 {
+		t.Errorf("Bad document count after an update")
+	}
+
+	if err := db.UpdateDefinition("chart", "A map."); err == nil {
+		t.Errorf("Updated a word that isn't there")
+	}
+}
+
+func TestStructuredDefinition(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.WriteStructuredDefinition(&dictd.Definition{
+		Word:          "kluge",
+		Pronunciation: "klooj",
+		Senses:        []string{"A clever hack.", "Something that works."},
+		Related:       map[string][]string{"see-also": {"hack"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defs := db.Define("test", "kluge")
+	if len(defs) != 1 || defs[0].Pronunciation != "klooj" || len(defs[0].Senses) != 2 {
+		t.Fatalf("Lost the structured fields")
+	}
+
+	if defs[0].Modified == nil || defs[0].Related["see-also"][0] != "hack" {
+		t.Errorf("Lost the related words or modification time")
+	}
+
+	if len(db.matchFullText("clever")) != 1 {
+		t.Errorf("Rendered definition didn't get indexed")
+	}
+
+	/* Writing it again as plain text drops the structure. */
+	db.WriteDefinition("kluge", "A hack.")
+	if defs := db.Define("test", "kluge"); defs[0].Pronunciation != "" {
+		t.Errorf("Stale structured fields after a rewrite")
+	}
+}
+
+func TestPlainDefinitionNotStamped(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteStructuredDefinition(&dictd.Definition{Word: "hack", Definition: "A quick job."})
+	db.WriteStructuredDefinition(&dictd.Definition{Word: "cruft", Definition: "Junk.", Source: "jargon"})
+
+	if defs := db.Define("test", "hack"); defs[0].Text() != "A quick job." {
+		t.Errorf("Plain definition changed: %q", defs[0].Text())
+	}
+	if defs := db.Define("test", "cruft"); defs[0].Modified != nil {
+		t.Errorf("Stamped a definition with no structure")
+	}
+}
+
+func TestCaseDistinctHeadwords(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteDefinition("Nice", "A city in France.")
+	db.WriteDefinition("nice", "Pleasant.")
+
+	defs := db.Define("test", "nice")
+	if len(defs) != 2 || defs[0].Word != "nice" || defs[1].Definition != "A city in France." {
+		t.Fatalf("Lost a headword: %v", defs)
+	}
+	if defs := db.Define("test", "Nice"); defs[0].Word != "Nice" {
+		t.Errorf("Didn't put the exact headword first")
+	}
+
+	words := []string{}
+	for _, el := range db.Match("test", "nic", "prefix") {
+		words = append(words, el.Word)
+	}
+	if strings.Join(words, ",") != "Nice,nice" {
+		t.Errorf("Bad matches %q", words)
+	}
+
+	/* Rewriting one leaves the other alone. */
+	db.WriteDefinition("nice", "Kind.")
+	defs = db.Define("test", "nice")
+	if len(defs) != 2 || defs[0].Definition != "Kind." || defs[1].Definition != "A city in France." {
+		t.Errorf("Rewrite clobbered the other headword: %v", defs)
+	}
+	if len(db.matchFullText("france")) != 1 || len(db.matchFullText("kind")) != 1 {
+		t.Errorf("Both definitions should be in the full text index")
+	}
+
+	if err := db.DeleteDefinition("Nice"); err != nil {
+		t.Fatal(err)
+	}
+	defs = db.Define("test", "nice")
+	if len(defs) != 1 || defs[0].Word != "nice" {
+		t.Errorf("Deleted the wrong headword: %v", defs)
+	}
+	if len(db.matchFullText("france")) != 0 {
+		t.Errorf("Deleted definition is still in the full text index")
+	}
+
+	report, err := db.Verify([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("Indexes are off: %v %v", report.Orphans, report.Missing)
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteDefinition("café", "Coffee.")
+	db.WriteDefinition("running", "Going quickly.")
+	db.WriteFrequency("running", 10)
+	db.WriteFrequency("never defined", 3)
+
+	/* Like a db from before the deletion index. */
+	db.db.Delete([]byte("meta\ndeletes"), nil)
+
+	/* Deleting by word after this would miss "café", and the stem of
+	 * "running". */
+	loader := NewBulkLoader(db)
+	removed, err := loader.DeleteAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetStripDiacritics(true)
+	db.SetLanguage("french")
+
+	if removed != 2 || !db.Empty() {
+		t.Errorf("Removed %d words, and left some", removed)
+	}
+	if _, err := db.get("meta", "deletes"); err != nil {
+		t.Errorf("Deletion index still off after emptying the db")
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for namespace, count := range stats {
+		if namespace != "meta" {
+			t.Errorf("%d keys left in %s", count, namespace)
+		}
+	}
+}
@@ -0,0 +1,704 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* doublemetaphone.go - Lawrence Philips' Double Metaphone.
+ *
+ * jellyfish gives us the original Metaphone, but not Double Metaphone,
+ * which is a whole lot better with words that came into English from
+ * somewhere else. Each word gets a primary key and an alternate key (for
+ * the "other" way to say it), and two words sound alike if any of their
+ * keys match.
+ *
+ * This follows the structure of the original C++ (and the Apache Commons
+ * Codec port), one handler per letter, with all of the rules and special
+ * cases in the same order, so it can be checked against either. */
+
+import (
+	"strings"
+)
+
+/* How long Double Metaphone keys get. Four is the traditional length. */
+const doubleMetaphoneLength = 4
+
+/* Both keys, as they're being built up. */
+type doubleMetaphoneResult struct {
+	primary   string
+	alternate string
+}
+
+func (this *doubleMetaphoneResult) appendPrimary(value string) {
+	remaining := doubleMetaphoneLength - len(this.primary)
+	if len(value) > remaining {
+		value = value[:remaining]
+	}
+	this.primary += value
+}
+
+func (this *doubleMetaphoneResult) appendAlternate(value string) {
+	remaining := doubleMetaphoneLength - len(this.alternate)
+	if len(value) > remaining {
+		value = value[:remaining]
+	}
+	this.alternate += value
+}
+
+/* Append `primary` to the primary key, and `alternate` (if given) to the
+ * alternate key, otherwise `primary` to both. */
+func (this *doubleMetaphoneResult) append(primary string, alternate ...string) {
+	this.appendPrimary(primary)
+	if len(alternate) > 0 {
+		this.appendAlternate(alternate[0])
+	} else {
+		this.appendAlternate(primary)
+	}
+}
+
+func (this *doubleMetaphoneResult) complete() bool {
+	return len(this.primary) >= doubleMetaphoneLength &&
+		len(this.alternate) >= doubleMetaphoneLength
+}
+
+/* The word being encoded, with some helpers for poking around in it. */
+type doubleMetaphoneWord []rune
+
+/* Get the letter at `index`, or 0 if that's off either end. */
+func (this doubleMetaphoneWord) at(index int) rune {
+	if index < 0 || index >= len(this) {
+		return 0
+	}
+	return this[index]
+}
+
+/* Check to see if the `length` letters at `start` are any of `options`. */
+func (this doubleMetaphoneWord) contains(start int, length int, options ...string) bool {
+	if start < 0 || start+length > len(this) {
+		return false
+	}
+	target := string(this[start : start+length])
+	for _, el := range options {
+		if el == target {
+			return true
+		}
+	}
+	return false
+}
+
+func (this doubleMetaphoneWord) last() int {
+	return len(this) - 1
+}
+
+func isDoubleMetaphoneVowel(el rune) bool {
+	return strings.ContainsRune("AEIOUY", el)
+}
+
+/* Given a word `word`, get its primary and alternate Double Metaphone
+ * keys. Words that only have one sensible pronunciation get the same key
+ * back twice. */
+func doubleMetaphone(word string) (string, string) {
+	value := doubleMetaphoneWord(strings.ToUpper(strings.TrimSpace(word)))
+	if len(value) == 0 {
+		return "", ""
+	}
+
+	text := string(value)
+	slavoGermanic := strings.ContainsAny(text, "WK") ||
+		strings.Contains(text, "CZ") || strings.Contains(text, "WITZ")
+
+	result := &doubleMetaphoneResult{}
+	index := 0
+
+	/* Skip these when at the start of a word */
+	if value.contains(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		index = 1
+	}
+
+	for !result.complete() && index <= value.last() {
+		switch value.at(index) {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			/* All initial vowels map to "A" */
+			if index == 0 {
+				result.append("A")
+			}
+			index++
+		case 'B':
+			/* "-mb", e.g. "dumb", is handled under M */
+			result.append("P")
+			index = skip(value, index, 'B')
+		case 'Ç':
+			result.append("S")
+			index++
+		case 'C':
+			index = dmHandleC(value, result, index)
+		case 'D':
+			index = dmHandleD(value, result, index)
+		case 'F':
+			result.append("F")
+			index = skip(value, index, 'F')
+		case 'G':
+			index = dmHandleG(value, result, index, slavoGermanic)
+		case 'H':
+			index = dmHandleH(value, result, index)
+		case 'J':
+			index = dmHandleJ(value, result, index, slavoGermanic)
+		case 'K':
+			result.append("K")
+			index = skip(value, index, 'K')
+		case 'L':
+			index = dmHandleL(value, result, index)
+		case 'M':
+			result.append("M")
+			if dmConditionM0(value, index) {
+				index += 2
+			} else {
+				index++
+			}
+		case 'N':
+			result.append("N")
+			index = skip(value, index, 'N')
+		case 'Ñ':
+			result.append("N")
+			index++
+		case 'P':
+			index = dmHandleP(value, result, index)
+		case 'Q':
+			result.append("K")
+			index = skip(value, index, 'Q')
+		case 'R':
+			index = dmHandleR(value, result, index, slavoGermanic)
+		case 'S':
+			index = dmHandleS(value, result, index, slavoGermanic)
+		case 'T':
+			index = dmHandleT(value, result, index)
+		case 'V':
+			result.append("F")
+			index = skip(value, index, 'V')
+		case 'W':
+			index = dmHandleW(value, result, index)
+		case 'X':
+			index = dmHandleX(value, result, index)
+		case 'Z':
+			index = dmHandleZ(value, result, index, slavoGermanic)
+		default:
+			index++
+		}
+	}
+
+	return result.primary, result.alternate
+}
+
+/* Step over the letter at `index`, and the one after it too if it's
+ * another `letter`. */
+func skip(value doubleMetaphoneWord, index int, letter rune) int {
+	if value.at(index+1) == letter {
+		return index + 2
+	}
+	return index + 1
+}
+
+func dmHandleC(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	switch {
+	case dmConditionC0(value, index):
+		/* Various Germanic */
+		result.append("K")
+		index += 2
+	case index == 0 && value.contains(index, 6, "CAESAR"):
+		result.append("S")
+		index += 2
+	case value.contains(index, 2, "CH"):
+		index = dmHandleCH(value, result, index)
+	case value.contains(index, 2, "CZ") && !value.contains(index-2, 4, "WICZ"):
+		/* "Czerny" */
+		result.append("S", "X")
+		index += 2
+	case value.contains(index+1, 3, "CIA"):
+		/* "focaccia" */
+		result.append("X")
+		index += 3
+	case value.contains(index, 2, "CC") && !(index == 1 && value.at(0) == 'M'):
+		/* Double "cc", but not "McClelland" */
+		return dmHandleCC(value, result, index)
+	case value.contains(index, 2, "CK", "CG", "CQ"):
+		result.append("K")
+		index += 2
+	case value.contains(index, 2, "CI", "CE", "CY"):
+		/* Italian vs. English */
+		if value.contains(index, 3, "CIO", "CIE", "CIA") {
+			result.append("S", "X")
+		} else {
+			result.append("S")
+		}
+		index += 2
+	default:
+		result.append("K")
+		if value.contains(index+1, 2, " C", " Q", " G") {
+			/* "Mac Caffrey", "Mac Gregor" */
+			index += 3
+		} else if value.contains(index+1, 1, "C", "K", "Q") &&
+			!value.contains(index+1, 2, "CE", "CI") {
+			index += 2
+		} else {
+			index++
+		}
+	}
+	return index
+}
+
+func dmHandleCC(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	if value.contains(index+2, 1, "I", "E", "H") &&
+		!value.contains(index+2, 2, "HU") {
+		/* "bellocchio", but not "bacchus" */
+		if (index == 1 && value.at(index-1) == 'A') ||
+			value.contains(index-1, 5, "UCCEE", "UCCES") {
+			/* "accident", "accede", "succeed" */
+			result.append("KS")
+		} else {
+			/* "bacci", "bertucci", other Italian */
+			result.append("X")
+		}
+		return index + 3
+	}
+	/* Pierce's rule */
+	result.append("K")
+	return index + 2
+}
+
+func dmHandleCH(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	switch {
+	case index > 0 && value.contains(index, 4, "CHAE"):
+		/* "Michael" */
+		result.append("K", "X")
+	case dmConditionCH0(value, index):
+		/* Greek roots ("chemistry", "chorus", etc.) */
+		result.append("K")
+	case dmConditionCH1(value, index):
+		/* Germanic, Greek, or otherwise "ch" for "kh" sound */
+		result.append("K")
+	case index > 0:
+		if value.contains(0, 2, "MC") {
+			result.append("K")
+		} else {
+			result.append("X", "K")
+		}
+	default:
+		result.append("X")
+	}
+	return index + 2
+}
+
+func dmHandleD(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	if value.contains(index, 2, "DG") {
+		if value.contains(index+2, 1, "I", "E", "Y") {
+			/* "edge" */
+			result.append("J")
+			return index + 3
+		}
+		/* "Edgar" */
+		result.append("TK")
+		return index + 2
+	}
+	result.append("T")
+	if value.contains(index, 2, "DT", "DD") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func dmHandleG(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int, slavoGermanic bool) int {
+	switch {
+	case value.at(index+1) == 'H':
+		return dmHandleGH(value, result, index)
+	case value.at(index+1) == 'N':
+		if index == 1 && isDoubleMetaphoneVowel(value.at(0)) && !slavoGermanic {
+			result.append("KN", "N")
+		} else if !value.contains(index+2, 2, "EY") &&
+			value.at(index+1) != 'Y' && !slavoGermanic {
+			result.append("N", "KN")
+		} else {
+			result.append("KN")
+		}
+		return index + 2
+	case value.contains(index+1, 2, "LI") && !slavoGermanic:
+		/* "tagliaro" */
+		result.append("KL", "L")
+		return index + 2
+	case index == 0 && (value.at(index+1) == 'Y' || value.contains(index+1, 2,
+		"ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		/* -ges-, -gep-, -gel-, -gie- at the beginning */
+		result.append("K", "J")
+		return index + 2
+	case (value.contains(index+1, 2, "ER") || value.at(index+1) == 'Y') &&
+		!value.contains(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!value.contains(index-1, 1, "E", "I") &&
+		!value.contains(index-1, 3, "RGY", "OGY"):
+		/* -ger-, -gy- */
+		result.append("K", "J")
+		return index + 2
+	case value.contains(index+1, 1, "E", "I", "Y") ||
+		value.contains(index-1, 4, "AGGI", "OGGI"):
+		/* Italian, "biaggi" */
+		if value.contains(0, 4, "VAN ", "VON ") ||
+			value.contains(0, 3, "SCH") ||
+			value.contains(index+1, 2, "ET") {
+			/* Obvious Germanic */
+			result.append("K")
+		} else if value.contains(index+1, 3, "IER") {
+			result.append("J")
+		} else {
+			result.append("J", "K")
+		}
+		return index + 2
+	case value.at(index+1) == 'G':
+		result.append("K")
+		return index + 2
+	default:
+		result.append("K")
+		return index + 1
+	}
+}
+
+func dmHandleGH(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	switch {
+	case index > 0 && !isDoubleMetaphoneVowel(value.at(index-1)):
+		result.append("K")
+	case index == 0:
+		/* "ghislane", "ghiradelli" */
+		if value.at(index+2) == 'I' {
+			result.append("J")
+		} else {
+			result.append("K")
+		}
+	case (index > 1 && value.contains(index-2, 1, "B", "H", "D")) ||
+		(index > 2 && value.contains(index-3, 1, "B", "H", "D")) ||
+		(index > 3 && value.contains(index-4, 1, "B", "H")):
+		/* Parker's rule (with some further refinements), "hugh" */
+	default:
+		if index > 2 && value.at(index-1) == 'U' &&
+			value.contains(index-3, 1, "C", "G", "L", "R", "T") {
+			/* "laugh", "McLaughlin", "cough", "gough", "rough", "tough" */
+			result.append("F")
+		} else if index > 0 && value.at(index-1) != 'I' {
+			result.append("K")
+		}
+	}
+	return index + 2
+}
+
+func dmHandleH(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	/* Only keep if first & before vowel, or between two vowels. This also
+	 * takes care of "HH". */
+	if (index == 0 || isDoubleMetaphoneVowel(value.at(index-1))) &&
+		isDoubleMetaphoneVowel(value.at(index+1)) {
+		result.append("H")
+		return index + 2
+	}
+	return index + 1
+}
+
+func dmHandleJ(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int, slavoGermanic bool) int {
+	if value.contains(index, 4, "JOSE") || value.contains(0, 4, "SAN ") {
+		/* Obvious Spanish, "Jose", "San Jacinto" */
+		if (index == 0 && value.at(index+4) == ' ') ||
+			len(value) == 4 || value.contains(0, 4, "SAN ") {
+			result.append("H")
+		} else {
+			result.append("J", "H")
+		}
+		return index + 1
+	}
+
+	if index == 0 {
+		/* "Yankelovich", "Jankelowicz" */
+		result.append("J", "A")
+	} else if isDoubleMetaphoneVowel(value.at(index-1)) && !slavoGermanic &&
+		(value.at(index+1) == 'A' || value.at(index+1) == 'O') {
+		/* Spanish pronunciation of "bajador" */
+		result.append("J", "H")
+	} else if index == value.last() {
+		result.append("J", "")
+	} else if !value.contains(index+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") &&
+		!value.contains(index-1, 1, "S", "K", "L") {
+		result.append("J")
+	}
+	return skip(value, index, 'J')
+}
+
+func dmHandleL(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	if value.at(index+1) == 'L' {
+		if dmConditionL0(value, index) {
+			/* Spanish, "cabrillo", "gallegos" */
+			result.appendPrimary("L")
+		} else {
+			result.append("L")
+		}
+		return index + 2
+	}
+	result.append("L")
+	return index + 1
+}
+
+func dmHandleP(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	if value.at(index+1) == 'H' {
+		result.append("F")
+		return index + 2
+	}
+	result.append("P")
+	/* Also account for "campbell" and "raspberry" */
+	if value.contains(index+1, 1, "P", "B") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func dmHandleR(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int, slavoGermanic bool) int {
+	if index == value.last() && !slavoGermanic &&
+		value.contains(index-2, 2, "IE") &&
+		!value.contains(index-4, 2, "ME", "MA") {
+		/* French, "rogier", but exclude "hochmeier" */
+		result.appendAlternate("R")
+	} else {
+		result.append("R")
+	}
+	return skip(value, index, 'R')
+}
+
+func dmHandleS(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int, slavoGermanic bool) int {
+	switch {
+	case value.contains(index-1, 3, "ISL", "YSL"):
+		/* Special cases "island", "isle", "carlisle", "carlysle" */
+		return index + 1
+	case index == 0 && value.contains(index, 5, "SUGAR"):
+		/* Special case "sugar-" */
+		result.append("X", "S")
+		return index + 1
+	case value.contains(index, 2, "SH"):
+		if value.contains(index+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			/* Germanic */
+			result.append("S")
+		} else {
+			result.append("X")
+		}
+		return index + 2
+	case value.contains(index, 3, "SIO", "SIA") || value.contains(index, 4, "SIAN"):
+		/* Italian and Armenian */
+		if slavoGermanic {
+			result.append("S")
+		} else {
+			result.append("S", "X")
+		}
+		return index + 3
+	case (index == 0 && value.contains(index+1, 1, "M", "N", "L", "W")) ||
+		value.contains(index+1, 1, "Z"):
+		/* German & anglicisations, "smith" matches "schmidt", "snider"
+		 * matches "schneider". Also, -sz- in Slavic languages, although
+		 * in Hungarian it's pronounced "s". */
+		result.append("S", "X")
+		return skip(value, index, 'Z')
+	case value.contains(index, 2, "SC"):
+		return dmHandleSC(value, result, index)
+	default:
+		if index == value.last() && value.contains(index-2, 2, "AI", "OI") {
+			/* French, "resnais", "artois" */
+			result.appendAlternate("S")
+		} else {
+			result.append("S")
+		}
+		if value.contains(index+1, 1, "S", "Z") {
+			return index + 2
+		}
+		return index + 1
+	}
+}
+
+func dmHandleSC(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	switch {
+	case value.at(index+2) == 'H':
+		/* Schlesinger's rule */
+		if value.contains(index+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+			/* Dutch origin, "school", "schooner" */
+			if value.contains(index+3, 2, "ER", "EN") {
+				/* "schermerhorn", "schenker" */
+				result.append("X", "SK")
+			} else {
+				result.append("SK")
+			}
+		} else if index == 0 && !isDoubleMetaphoneVowel(value.at(3)) &&
+			value.at(3) != 'W' {
+			result.append("X", "S")
+		} else {
+			result.append("X")
+		}
+	case value.contains(index+2, 1, "I", "E", "Y"):
+		result.append("S")
+	default:
+		result.append("SK")
+	}
+	return index + 3
+}
+
+func dmHandleT(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	switch {
+	case value.contains(index, 4, "TION"), value.contains(index, 3, "TIA", "TCH"):
+		result.append("X")
+		return index + 3
+	case value.contains(index, 2, "TH") || value.contains(index, 3, "TTH"):
+		if value.contains(index+2, 2, "OM", "AM") ||
+			value.contains(0, 4, "VAN ", "VON ") ||
+			value.contains(0, 3, "SCH") {
+			/* Special case "thomas", "thames", or Germanic */
+			result.append("T")
+		} else {
+			result.append("0", "T")
+		}
+		return index + 2
+	default:
+		result.append("T")
+		if value.contains(index+1, 1, "T", "D") {
+			return index + 2
+		}
+		return index + 1
+	}
+}
+
+func dmHandleW(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	switch {
+	case value.contains(index, 2, "WR"):
+		/* Can also be in the middle of a word */
+		result.append("R")
+		return index + 2
+	case index == 0 && (isDoubleMetaphoneVowel(value.at(index+1)) ||
+		value.contains(index, 2, "WH")):
+		if isDoubleMetaphoneVowel(value.at(index + 1)) {
+			/* "Wasserman" should match "Vasserman" */
+			result.append("A", "F")
+		} else {
+			/* Need "Uomo" to match "Womo" */
+			result.append("A")
+		}
+		return index + 1
+	case (index == value.last() && isDoubleMetaphoneVowel(value.at(index-1))) ||
+		value.contains(index-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") ||
+		value.contains(0, 3, "SCH"):
+		/* "Arnow" should match "Arnoff" */
+		result.appendAlternate("F")
+		return index + 1
+	case value.contains(index, 4, "WICZ", "WITZ"):
+		/* Polish, "filipowicz" */
+		result.append("TS", "FX")
+		return index + 4
+	default:
+		return index + 1
+	}
+}
+
+func dmHandleX(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int) int {
+	if index == 0 {
+		/* "Xavier" */
+		result.append("S")
+		return index + 1
+	}
+	if !(index == value.last() &&
+		(value.contains(index-3, 3, "IAU", "EAU") ||
+			value.contains(index-2, 2, "AU", "OU"))) {
+		/* French, "breaux" */
+		result.append("KS")
+	}
+	if value.contains(index+1, 1, "C", "X") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func dmHandleZ(value doubleMetaphoneWord, result *doubleMetaphoneResult, index int, slavoGermanic bool) int {
+	if value.at(index+1) == 'H' {
+		/* Chinese pinyin, "zhao" */
+		result.append("J")
+		return index + 2
+	}
+	if value.contains(index+1, 2, "ZO", "ZI", "ZA") ||
+		(slavoGermanic && index > 0 && value.at(index-1) != 'T') {
+		result.append("S", "TS")
+	} else {
+		result.append("S")
+	}
+	return skip(value, index, 'Z')
+}
+
+/* Complex condition 0 for "C" */
+func dmConditionC0(value doubleMetaphoneWord, index int) bool {
+	if value.contains(index, 4, "CHIA") {
+		return true
+	} else if index <= 1 {
+		return false
+	} else if isDoubleMetaphoneVowel(value.at(index - 2)) {
+		return false
+	} else if !value.contains(index-1, 3, "ACH") {
+		return false
+	}
+	next := value.at(index + 2)
+	return (next != 'I' && next != 'E') ||
+		value.contains(index-2, 6, "BACHER", "MACHER")
+}
+
+/* Complex condition 0 for "CH" */
+func dmConditionCH0(value doubleMetaphoneWord, index int) bool {
+	if index != 0 {
+		return false
+	} else if !value.contains(index+1, 5, "HARAC", "HARIS") &&
+		!value.contains(index+1, 3, "HOR", "HYM", "HIA", "HEM") {
+		return false
+	} else if value.contains(0, 5, "CHORE") {
+		return false
+	}
+	return true
+}
+
+/* Complex condition 1 for "CH" */
+func dmConditionCH1(value doubleMetaphoneWord, index int) bool {
+	return value.contains(0, 4, "VAN ", "VON ") ||
+		value.contains(0, 3, "SCH") ||
+		value.contains(index-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+		value.contains(index+2, 1, "T", "S") ||
+		((value.contains(index-1, 1, "A", "O", "U", "E") || index == 0) &&
+			(value.contains(index+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") ||
+				index+1 == value.last()))
+}
+
+/* Complex condition 0 for "L" */
+func dmConditionL0(value doubleMetaphoneWord, index int) bool {
+	if index == len(value)-3 &&
+		value.contains(index-1, 4, "ILLO", "ILLA", "ALLE") {
+		return true
+	}
+	return (value.contains(len(value)-2, 2, "AS", "OS") ||
+		value.contains(len(value)-1, 1, "A", "O")) &&
+		value.contains(index-1, 4, "ALLE")
+}
+
+/* Complex condition 0 for "M" */
+func dmConditionM0(value doubleMetaphoneWord, index int) bool {
+	if value.at(index+1) == 'M' {
+		return true
+	}
+	return value.contains(index-1, 3, "UMB") &&
+		(index+1 == value.last() || value.contains(index+2, 2, "ER"))
+}
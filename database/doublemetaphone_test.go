@@ -0,0 +1,33 @@
+package database
+
+import (
+	"testing"
+)
+
+func checkDoubleMetaphone(t *testing.T, word string, primary string, alternate string) {
+	p, a := doubleMetaphone(word)
+	if p != primary || a != alternate {
+		t.Errorf("Bad keys for %s: got %s/%s, wanted %s/%s",
+			word, p, a, primary, alternate)
+	}
+}
+
+func TestDoubleMetaphoneSimple(t *testing.T) {
+	checkDoubleMetaphone(t, "Thompson", "TMPS", "TMPS")
+	checkDoubleMetaphone(t, "hacker", "HKR", "HKR")
+}
+
+func TestDoubleMetaphoneAlternates(t *testing.T) {
+	checkDoubleMetaphone(t, "Smith", "SM0", "XMT")
+	checkDoubleMetaphone(t, "Schmidt", "XMT", "SMT")
+	checkDoubleMetaphone(t, "Xavier", "SF", "SFR")
+	checkDoubleMetaphone(t, "Arnow", "ARN", "ARNF")
+}
+
+func TestDoubleMetaphoneSpanish(t *testing.T) {
+	checkDoubleMetaphone(t, "Jose", "HS", "HS")
+}
+
+func TestDoubleMetaphoneEmpty(t *testing.T) {
+	checkDoubleMetaphone(t, "", "", "")
+}
@@ -0,0 +1,228 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* fulltext.go - full text search over definition bodies.
+ *
+ * Everything else in the LevelDB backend indexes headwords. This indexes
+ * what the definitions say, so people can search by concept ("a bill that
+ * spends money") rather than having to know the term.
+ *
+ * Definitions are split into words, case folded, stemmed, and have the
+ * boring words (stopwords) thrown out. We then keep:
+ *
+ *  "fulltext\n{term}"   - the usual newline delimited list of keys whose
+ *                         definitions use that term.
+ *  "terms\n{key}"       - the term vector for that definition, one
+ *                         "{term} {count}" per line.
+ *  "meta\nfulltext-docs", "meta\nfulltext-length"
+ *                       - how many definitions we've indexed, and how many
+ *                         terms they have between them.
+ *
+ * Which is everything BM25 needs to rank the results. */
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* BM25 tuning, the usual textbook values. */
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+/* Words that show up in nearly every (English) definition, and so tell us
+ * nothing about any of them. */
+var stopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "and": true, "are": true,
+	"as": true, "at": true, "be": true, "been": true, "but": true,
+	"by": true, "can": true, "for": true, "from": true, "has": true,
+	"have": true, "in": true, "into": true, "is": true, "it": true,
+	"its": true, "not": true, "of": true, "on": true, "or": true,
+	"so": true, "such": true, "that": true, "the": true, "their": true,
+	"there": true, "these": true, "this": true, "to": true, "was": true,
+	"were": true, "which": true, "who": true, "will": true, "with": true,
+}
+
+/* Given some text `text`, get the terms we'd index it under (duplicates
+ * and all, since we care how often they show up). */
+func (this *LevelDBDatabase) analyze(text string) (ret []string) {
+	for _, el := range splitWords(this.key(text)) {
+		if this.language == "english" && stopwords[el] {
+			continue
+		}
+		ret = append(ret, this.stem(el))
+	}
+	return
+}
+
+/* Stage the index of the definition `definition` of the word under `key`
+ * into `pending`. */
+func (this *LevelDBDatabase) stageFullText(pending *pendingWrite, key string, definition string) {
+	counts := map[string]int{}
+	length := 0
+	for _, el := range this.analyze(definition) {
+		counts[el]++
+		length++
+	}
+
+	terms := []string{}
+	for term, count := range counts {
+		pending.post("fulltext", term, key)
+		terms = append(terms, term+" "+strconv.Itoa(count))
+	}
+	sort.Strings(terms)
+
+	pending.put("terms", key, strings.Join(terms, "\n"))
+	pending.count("fulltext-docs", 1)
+	pending.count("fulltext-length", length)
+}
+
+/* Stage removing the word under `key` from the full text index into
+ * `pending`. We go by the term vector we stored, rather than the
+ * definition, in case the way we analyze text has changed since. */
+func (this *LevelDBDatabase) stageFullTextDelete(pending *pendingWrite, key string) {
+	old, err := this.lookup(pending, "terms", key)
+	if err != nil {
+		return
+	}
+
+	vector := parseTermVector(old)
+	for term := range vector {
+		pending.unpost("fulltext", term, key)
+	}
+	pending.remove("terms", key)
+	pending.count("fulltext-docs", -1)
+	pending.count("fulltext-length", -termVectorLength(vector))
+}
+
+/* Find the words whose definitions use every term in `query`, best first
+ * according to BM25. */
+func (this *LevelDBDatabase) matchFullText(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, el := range this.analyze(query) {
+		if !seen[el] {
+			seen[el] = true
+			terms = append(terms, el)
+		}
+	}
+	if len(terms) == 0 {
+		return []string{}
+	}
+
+	postings := map[string][]string{}
+	var candidates []string
+	for i, term := range terms {
+		postings[term] = this.matchFromIndex("fulltext", term)
+		if i == 0 {
+			candidates = postings[term]
+		} else {
+			candidates = intersect(candidates, postings[term])
+		}
+	}
+
+	docs := float64(this.counter("fulltext-docs"))
+	averageLength := 1.0
+	if docs > 0 {
+		averageLength = float64(this.counter("fulltext-length")) / docs
+	}
+
+	results := []scoredKey{}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		value, err := this.get("terms", candidate)
+		if err != nil {
+			continue
+		}
+		vector := parseTermVector(value)
+		length := float64(termVectorLength(vector))
+
+		score := 0.0
+		for _, term := range terms {
+			frequency := float64(vector[term])
+			if frequency == 0 {
+				/* A stale posting; the definition's moved on. */
+				score = -1
+				break
+			}
+			documentFrequency := float64(len(postings[term]))
+			idf := math.Log(1 + (docs-documentFrequency+0.5)/(documentFrequency+0.5))
+			score += idf * frequency * (bm25K1 + 1) /
+				(frequency + bm25K1*(1-bm25B+bm25B*length/averageLength))
+		}
+		if score < 0 {
+			continue
+		}
+		results = append(results, scoredKey{key: candidate, score: score})
+	}
+
+	sort.Sort(byScore(results))
+
+	ret := make([]string, len(results))
+	for i, el := range results {
+		ret[i] = el.key
+	}
+	return ret
+}
+
+/* Get the value of the "meta" counter `name`, or 0 if it's not set. */
+func (this *LevelDBDatabase) counter(name string) int {
+	value, err := this.get("meta", name)
+	if err != nil {
+		return 0
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+/* Parse a term vector as written by stageFullText. */
+func parseTermVector(value string) map[string]int {
+	vector := map[string]int{}
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if count, err := strconv.Atoi(fields[1]); err == nil {
+			vector[fields[0]] = count
+		}
+	}
+	return vector
+}
+
+/* How many terms (counting repeats) are in the term vector `vector`. */
+func termVectorLength(vector map[string]int) int {
+	length := 0
+	for _, count := range vector {
+		length += count
+	}
+	return length
+}
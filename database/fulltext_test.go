@@ -0,0 +1,21 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestTermVector(t *testing.T) {
+	vector := parseTermVector("appropri 2\nbill 1\nbogus\n")
+
+	if len(vector) != 2 {
+		t.Errorf("Bad term count out - didn't get 2")
+	}
+
+	if vector["appropri"] != 2 {
+		t.Errorf("Bad term frequency")
+	}
+
+	if termVectorLength(vector) != 3 {
+		t.Errorf("Bad term vector length")
+	}
+}
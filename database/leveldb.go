@@ -35,7 +35,13 @@ package database
  *  "{namespace}\n{key}", since \n is never valid in a search query (even if
  *  it was, we split onece on the first, soo, whatever :) )
  *
- * The actual definitions are in "\nword", all lower case.
+ * The actual definitions are in "\nkey", where the key is the normalized
+ * form of the headword (see NormalizeKey). The headword as the dictionary
+ * wrote it lives in "headword\nkey", so we can show "NASA" to the user
+ * even though we look it up as "nasa". Dictionaries can have more than
+ * one headword with the same key ("Nice", the city, and "nice"); the
+ * first one written lives in those two, and the rest are kept, as a JSON
+ * list of Definitions, in "variants\nkey".
  *
  * We also build up indexes using this, by storing the precomputed / rendered
  * lookup strings in a namespace. Something like soundex would be
@@ -43,8 +49,15 @@ package database
  * an O(1) lookup on that key. Magic, mirite. */
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"pault.ag/go/dictd/dictd"
 
@@ -52,6 +65,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/util"
 
 	"github.com/jamesturk/go-jellyfish"
+	"github.com/kljensen/snowball"
 )
 
 /* Create a new LevelDB Database. `path` should be the full filesystem path
@@ -66,19 +80,49 @@ func NewLevelDBDatabase(path string, description string) (*LevelDBDatabase, erro
 	databaseBackend := LevelDBDatabase{
 		description: description,
 		db:          db,
+		language:    "english",
+	}
+
+	/* Key normalization has to match what the database was built with,
+	 * so it's stored in the database rather than passed in. */
+	if value, err := databaseBackend.get("meta", "strip-diacritics"); err == nil {
+		databaseBackend.stripDiacritics = value == "true"
+	}
+	if value, err := databaseBackend.get("meta", "language"); err == nil {
+		databaseBackend.language = value
+	}
+
+	/* We can only trust the deletion index if every word went in with it,
+	 * so only turn it on for brand new databases. Older ones keep doing
+	 * a full scan until they're rebuilt. */
+	if _, err := databaseBackend.get("meta", "deletes"); err != nil {
+		if databaseBackend.empty() {
+			databaseBackend.write("meta", "deletes", strconv.Itoa(maxIndexedDistance))
+		}
 	}
 
 	return &databaseBackend, nil
 }
 
+/* The largest edit distance the "deletes" index can answer for. Each extra
+ * step of distance costs a lot more index, so two is where we stop. */
+const maxIndexedDistance = 2
+
 /* LevelDB Database container. This contains some fun bits (like the
  * leveldb.DB object, and the description the user gave us over in
  * NewLevelDBDatabase. */
 type LevelDBDatabase struct {
 	dictd.Database
 
-	description string
-	db          *leveldb.DB
+	description     string
+	db              *leveldb.DB
+	stripDiacritics bool
+	language        string
+	maxResults      int
+	stemFallback    bool
+
+	/* Held while writing, see batch.go */
+	lock sync.Mutex
 }
 
 /* Handle incoming RFC2229 MATCH requests.
@@ -86,64 +130,224 @@ type LevelDBDatabase struct {
  * Currently supported MATCH algorithms:
  *
  *  [default] - Metaphone
+ *            - Exact     (the headword itself, `exact` or `match`)
  *            - Prefix    (byte prefixes)
  *            - Soundex
- *            - Levenshtein
+ *            - Double Metaphone
+ *            - NYSIIS
+ *            - Match Rating Approach
+ *            - Levenshtein (distance 1, or `lev2` for distance 2)
+ *            - Regular expressions (`re`, or `regexp` for basic ones)
+ *            - Globs (`glob`, `wildcard`)
+ *            - Suffix
+ *            - Substring
+ *            - Word      (any word of a multi-word headword)
+ *            - Stem      (other inflections of the same word)
+ *            - Fulltext  (words in the definition, ranked by BM25)
+ *
+ * Results are ranked best-first (see rank.go), except for patterns, which
+ * don't have much of an idea of "close", so they stay in key order, and
+ * full text searches, which come back in their own order.
  */
 func (this *LevelDBDatabase) Match(name string, query string, strat string) (defs []*dictd.Definition) {
-	query = strings.ToLower(query)
+	/* Patterns get the query as the user sent it, since folding the case
+	 * of something like `\W` changes what it means. */
+	pattern := query
+	query = this.key(query)
 	var results []string
+	scored := true
 
 	switch strat {
+	case "exact", "match":
+		if _, err := this.get("", query); err == nil {
+			results = []string{query}
+		}
 	case "metaphone", ".":
 		results = this.matchMetaphone(query)
 	case "prefix":
 		results = this.scanPrefix(query)
 	case "soundex":
 		results = this.matchSoundex(query)
+	case "dmetaphone", "doublemetaphone":
+		results = this.matchDoubleMetaphone(query)
+	case "nysiis":
+		results = this.matchFromIndex("nysiis", jellyfish.Nysiis(query))
+	case "mra":
+		results = this.matchFromIndex("mra", jellyfish.MatchRatingCodex(query))
 	case "anagram":
 		results = this.matchAnagram(query)
-	case "levenshtein":
-		results = this.scanLevenshtein(query, 1)
+	case "levenshtein", "lev", "lev1":
+		results = this.matchLevenshtein(query, 1)
+	case "lev2":
+		results = this.matchLevenshtein(query, 2)
+	case "suffix":
+		results = this.scanSuffix(query)
+	case "substring":
+		results = this.matchSubstring(query)
+	case "word":
+		results = this.matchWord(query)
+	case "stem":
+		results = this.matchStem(query)
+	case "fulltext":
+		results = this.matchFullText(query)
+		scored = false
+	case "re":
+		results = this.scanPattern(pattern)
+		scored = false
+	case "regexp":
+		results = this.scanPattern(basicToPattern(pattern))
+		scored = false
+	case "glob", "wildcard":
+		results = this.scanPattern(globToPattern(pattern))
+		scored = false
+	}
+
+	if scored {
+		results = this.rank(query, results)
+	} else {
+		results = this.limit(results)
 	}
 
 	for _, el := range results {
-		def := &dictd.Definition{
-			DictDatabase:     this,
-			DictDatabaseName: name,
-			Word:             el,
+		for _, word := range this.headwords(el) {
+			defs = append(defs, &dictd.Definition{
+				DictDatabase:     this,
+				DictDatabaseName: name,
+				Word:             word,
+			})
 		}
-		defs = append(defs, def)
 	}
 
 	return
 }
 
-/* Handle incoming `DEFINE` calls. */
+/* Handle incoming `DEFINE` calls. Every headword with the query's key
+ * comes back, with the ones written exactly like the query first. */
 func (this *LevelDBDatabase) Define(name string, query string) []*dictd.Definition {
-	query = strings.ToLower(query)
-	data, err := this.get("", query)
-	if err != nil {
-		/* If we don't have the key, let's bail out. */
-		return make([]*dictd.Definition, 0)
+	key := this.key(query)
+	els := make([]*dictd.Definition, 0)
+
+	if defs := this.define(name, key); len(defs) > 0 {
+		exact := []*dictd.Definition{}
+		for _, el := range defs {
+			if el.Word == query {
+				exact = append(exact, el)
+			} else {
+				els = append(els, el)
+			}
+		}
+		return append(exact, els...)
 	}
-	els := make([]*dictd.Definition, 1)
-	els[0] = &dictd.Definition{
-		DictDatabase:     this,
-		DictDatabaseName: name,
-		Word:             query,
-		Definition:       string(data),
+
+	/* If we don't have the key, we can try to find the word it's an
+	 * inflection of ("running" for "run"), if we've been asked to.
+	 * Otherwise, let's bail out. */
+	if !this.stemFallback {
+		return els
+	}
+
+	for _, el := range this.matchStem(key) {
+		els = append(els, this.define(name, el)...)
 	}
 	return els
 }
 
+/* Get the Definitions under key `key`, if there are any. */
+func (this *LevelDBDatabase) define(name string, key string) []*dictd.Definition {
+	defs := this.entries(nil, key)
+	for _, el := range defs {
+		el.DictDatabase = this
+		el.DictDatabaseName = name
+	}
+	return defs
+}
+
+/* Get every entry filed under the key `key`, as it will be once `pending`
+ * is flushed (or as it is now, if `pending` is nil): the first headword
+ * written, then any others with the same key. */
+func (this *LevelDBDatabase) entries(pending *pendingWrite, key string) []*dictd.Definition {
+	data, err := this.lookup(pending, "", key)
+	if err != nil {
+		return []*dictd.Definition{}
+	}
+	def := dictd.Definition{}
+
+	/* Anything more than the text is kept alongside it, as JSON. */
+	if structured, err := this.lookup(pending, "structured", key); err == nil {
+		if err := json.Unmarshal([]byte(structured), &def); err != nil {
+			log.Printf("Bad structured definition for %s: %s", key, err)
+		}
+	}
+
+	/* Databases written before we kept track of the headword fall back
+	 * to the key itself. */
+	def.Word = key
+	if headword, err := this.lookup(pending, "headword", key); err == nil {
+		def.Word = headword
+	}
+	def.Definition = data
+	ret := []*dictd.Definition{&def}
+
+	if data, err := this.lookup(pending, "variants", key); err == nil {
+		variants := []*dictd.Definition{}
+		if err := json.Unmarshal([]byte(data), &variants); err != nil {
+			log.Printf("Bad variants for %s: %s", key, err)
+		}
+		ret = append(ret, variants...)
+	}
+	return ret
+}
+
+/* Get just the headwords under key `key` (see entries), without reading
+ * the definitions themselves, for MATCH. */
+func (this *LevelDBDatabase) headwords(key string) []string {
+	headword, err := this.get("headword", key)
+	if err != nil {
+		/* Databases written before we kept track of the headword fall
+		 * back to the key itself, if it's there at all. */
+		if _, err := this.get("", key); err != nil {
+			return []string{}
+		}
+		headword = key
+	}
+	ret := []string{headword}
+
+	if data, err := this.get("variants", key); err == nil {
+		variants := []struct {
+			Word string `json:"word"`
+		}{}
+		if err := json.Unmarshal([]byte(data), &variants); err != nil {
+			log.Printf("Bad variants for %s: %s", key, err)
+		}
+		for _, el := range variants {
+			ret = append(ret, el.Word)
+		}
+	}
+	return ret
+}
+
 /* Get all valid Strategies */
 func (this *LevelDBDatabase) Strategies(name string) map[string]string {
 	return map[string]string{
+		"exact":       "Match headwords exactly",
 		"levenshtein": "Levenshtein distance",
+		"lev1":        "Levenshtein distance of at most 1",
+		"lev2":        "Levenshtein distance of at most 2",
 		"soundex":     "Soundex matches",
 		"metaphone":   "Metaphone matches",
+		"dmetaphone":  "Double Metaphone matches",
+		"nysiis":      "NYSIIS matches",
+		"mra":         "Match Rating Approach matches",
 		"anagram":     "Anagram matches",
+		"re":          "POSIX 1003.2 (modern) regular expressions",
+		"regexp":      "Old (basic) regular expressions",
+		"glob":        "Shell style globs (*, ? and [...])",
+		"wildcard":    "Shell style globs (*, ? and [...])",
+		"suffix":      "Match based on the word's suffix",
+		"substring":   "Match words containing the query",
+		"word":        "Match any single word of a headword",
+		"stem":        "Match other inflections of the word",
+		"fulltext":    "Search the text of definitions",
 	}
 }
 
@@ -160,6 +364,109 @@ func (this *LevelDBDatabase) Description(name string) string {
 
 /* DB Specific calls below */
 
+/* Ignore diacritics when building lookup keys, so "café" can be found as
+ * "cafe". This is persisted in the database, and has to be set before any
+ * definitions are written, since existing keys aren't rewritten. */
+func (this *LevelDBDatabase) SetStripDiacritics(stripDiacritics bool) {
+	this.stripDiacritics = stripDiacritics
+	if stripDiacritics {
+		this.write("meta", "strip-diacritics", "true")
+	} else {
+		this.write("meta", "strip-diacritics", "false")
+	}
+}
+
+/* Set the language `language` (as Snowball names them, "english",
+ * "french", ...) the words in this database are in, so we know how to
+ * stem them. Like SetStripDiacritics, this is persisted in the database,
+ * and has to be set before any definitions are written. */
+func (this *LevelDBDatabase) SetLanguage(language string) {
+	this.language = language
+	this.write("meta", "language", language)
+}
+
+/* Check to see if we ignore diacritics when building lookup keys. */
+func (this *LevelDBDatabase) StripDiacritics() bool {
+	return this.stripDiacritics
+}
+
+/* Get the language the words in this database are stemmed as. */
+func (this *LevelDBDatabase) Language() string {
+	return this.language
+}
+
+/* Check to see if there aren't any words in the database yet. */
+func (this *LevelDBDatabase) Empty() bool {
+	return this.empty()
+}
+
+/* When a DEFINE misses, look for the word the query is an inflection of,
+ * and define that instead. */
+func (this *LevelDBDatabase) SetStemFallback(stemFallback bool) {
+	this.stemFallback = stemFallback
+}
+
+/* Get the stem of the word (or words) `key`, in the database's language.
+ * If the stemmer doesn't know the language, words are left as they are. */
+func (this *LevelDBDatabase) stem(key string) string {
+	words := strings.Split(key, " ")
+	for i, el := range words {
+		stemmed, err := snowball.Stem(el, this.language, true)
+		if err == nil && stemmed != "" {
+			words[i] = stemmed
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+/* Only return the best `maxResults` results for a MATCH. Zero (the
+ * default) means there's no limit. */
+func (this *LevelDBDatabase) SetMaxResults(maxResults int) {
+	this.maxResults = maxResults
+}
+
+/* Record how often the word `word` is used (from a corpus, or lookup
+ * counts, or whatever you've got), to help rank MATCH results. */
+func (this *LevelDBDatabase) WriteFrequency(word string, count int) {
+	this.write("frequency", this.key(word), strconv.Itoa(count))
+}
+
+/* Get the normalized lookup key for the word `word`. */
+func (this *LevelDBDatabase) key(word string) string {
+	return NormalizeKey(word, this.stripDiacritics)
+}
+
+/* Call `fn` with the headword and definition of every word in the
+ * database, in key order. If `fn` returns an error, we stop and hand it
+ * back. */
+func (this *LevelDBDatabase) ForEach(fn func(word string, definition string) error) error {
+	return this.ForEachDefinition("", func(definition *dictd.Definition) error {
+		return fn(definition.Word, definition.Body())
+	})
+}
+
+/* Call `fn` with the Definition (structured fields and all) of every
+ * word in the database, in key order. If `fn` returns an error, we stop
+ * and hand it back. */
+func (this *LevelDBDatabase) ForEachDefinition(name string, fn func(definition *dictd.Definition) error) error {
+	iter := this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		for _, def := range this.define(name, string(iter.Key())[1:]) {
+			if err := fn(def); err != nil {
+				return err
+			}
+		}
+	}
+	return iter.Error()
+}
+
+/* Close the underlying LevelDB database. Don't use this one after. */
+func (this *LevelDBDatabase) Close() error {
+	return this.db.Close()
+}
+
 /*
  * Write a "namespaced" key into the LevelDB Database.
  *
@@ -180,28 +487,11 @@ func (this *LevelDBDatabase) get(namespace string, key string) (value string, er
 	return string(data), err
 }
 
-/* Given the namespace `namespace`, and key `key`, write the `word` in the
- * internal "list" syntax for the index. */
-func (this *LevelDBDatabase) writeIndex(namespace string, key string, word string) {
-	var values []string
-
-	data, err := this.get(namespace, key)
-
-	if err != nil {
-		values = []string{}
-	} else {
-		/* Values are newline delimed */
-		values = strings.Split(string(data), "\n")
-	}
-
-	for _, el := range values {
-		if el == word {
-			return
-		}
-	}
-
-	values = append(values, word)
-	this.write(namespace, key, strings.Join(values, "\n"))
+/* Check to see if there are any words in the LevelDB Database yet. */
+func (this *LevelDBDatabase) empty() bool {
+	iter := this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
+	defer iter.Release()
+	return !iter.Next()
 }
 
 /*
@@ -213,45 +503,403 @@ func sortString(word string) string {
 	return strings.Join(sorted, "")
 }
 
+/* Reverse `word`, character by character, for the suffix index. */
+func reverseString(word string) string {
+	runes := []rune(word)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+/* Split `word` into the words it's made of, so "ad hoc committee" and
+ * "hacker-ethic" can be found by any one of their parts. Apostrophes stay
+ * put, so "can't" is one word. */
+func tokens(word string) (ret []string) {
+	seen := map[string]bool{}
+	for _, token := range splitWords(word) {
+		if !seen[token] {
+			seen[token] = true
+			ret = append(ret, token)
+		}
+	}
+	return
+}
+
+/* Split `text` on anything that can't be part of a word, duplicates and
+ * all. */
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(el rune) bool {
+		return el != '\'' && !unicode.IsLetter(el) && !unicode.IsNumber(el)
+	})
+}
+
+/* Get every (unique) run of `n` characters in `word`. Words shorter than
+ * that don't have any. */
+func ngrams(word string, n int) (ret []string) {
+	runes := []rune(word)
+	seen := map[string]bool{}
+	for i := 0; i+n <= len(runes); i++ {
+		gram := string(runes[i : i+n])
+		if !seen[gram] {
+			seen[gram] = true
+			ret = append(ret, gram)
+		}
+	}
+	return
+}
+
 /*
  * Given a word `word`, defined by definition `definition`, write this out
- * to the LevelDB database, and generate all Indexes we need.
+ * to the LevelDB database, and generate all Indexes we need. If the word
+ * is already defined, the old definition (and its postings) are replaced.
  *
+ * The whole lot is written as one batch, so it's all there or none of it
+ * is. If you've got a lot of these to write, use a BulkLoader.
  */
-func (this *LevelDBDatabase) WriteDefinition(word string, definition string) {
-	/* Right, now let's build up indexes on the word */
+func (this *LevelDBDatabase) WriteDefinition(word string, definition string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	pending := newPendingWrite()
+	this.stageDefinition(pending, word, definition)
+	return this.flush(pending)
+}
+
+/* Write the Definition `definition` out, keeping any structured fields
+ * (pronunciation, senses and so on) as well as its text. If it has any,
+ * and doesn't say when it was last modified, it's now. */
+func (this *LevelDBDatabase) WriteStructuredDefinition(definition *dictd.Definition) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	pending := newPendingWrite()
+	this.stageStructuredDefinition(pending, definition)
+	return this.flush(pending)
+}
+
+/* Replace the definition of the word `word`, which has to exist already,
+ * with `definition`. */
+func (this *LevelDBDatabase) UpdateDefinition(word string, definition string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, err := this.get("", this.key(word)); err != nil {
+		return errors.New("No such word")
+	}
+
+	pending := newPendingWrite()
+	this.stageDefinition(pending, word, definition)
+	return this.flush(pending)
+}
+
+/* Remove the word `word` from the LevelDB database, and pull it out of
+ * every index it's in. If there's more than one headword with its key,
+ * only the one written exactly like `word` goes, unless none of them are,
+ * in which case they all do. */
+func (this *LevelDBDatabase) DeleteDefinition(word string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	pending := newPendingWrite()
+	if !this.stageDeleteWord(pending, word) {
+		return errors.New("No such word")
+	}
+	return this.flush(pending)
+}
+
+/* Stage everything WriteDefinition has to write for `word` into
+ * `pending`. The caller has to hold the write lock. */
+func (this *LevelDBDatabase) stageDefinition(pending *pendingWrite, word string, definition string) {
+	this.stageEntry(pending, &dictd.Definition{Word: word, Definition: definition})
+}
+
+/* Stage everything WriteStructuredDefinition has to write for
+ * `definition` into `pending`. The caller has to hold the write lock. */
+func (this *LevelDBDatabase) stageStructuredDefinition(pending *pendingWrite, definition *dictd.Definition) {
+	entry := *definition
+	entry.Definition = definition.Body()
+	entry.DictDatabase = nil
+	entry.DictDatabaseName = ""
+
+	/* Only stamp things that have some structure to them; a plain
+	 * definition that just says where it came from isn't news. */
+	bare := entry
+	bare.Source = ""
+	bare.Modified = nil
+	if entry.Modified == nil && bare.Structured() {
+		now := time.Now().UTC()
+		entry.Modified = &now
+	}
+
+	this.stageEntry(pending, &entry)
+}
 
-	this.write("", word, definition) /* no namespace for words */
+/* Stage writing the entry `entry`, replacing the one with the same
+ * headword, if there is one, or adding it alongside any others with the
+ * same key. The caller has to hold the write lock. */
+func (this *LevelDBDatabase) stageEntry(pending *pendingWrite, entry *dictd.Definition) {
+	/* Keep the headword as given for display, and do everything else
+	 * in terms of the normalized key. */
+	key := this.key(entry.Word)
+
+	entries := this.entries(pending, key)
+	replaced := false
+	for i, el := range entries {
+		if el.Word == entry.Word {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	/* Clear out the old postings first, or they'd hang around forever
+	 * if the new definitions don't have them. */
+	if len(entries) > 1 || replaced {
+		this.stageUnindex(pending, key)
+	}
+	this.stageEntries(pending, key, entries)
+}
+
+/* Stage writing `entries` as everything filed under `key`, and indexing
+ * them. The caller has to hold the write lock. */
+func (this *LevelDBDatabase) stageEntries(pending *pendingWrite, key string, entries []*dictd.Definition) {
+	primary := entries[0]
+	pending.put("", key, primary.Body()) /* no namespace for words */
+	pending.put("headword", key, primary.Word)
+
+	/* Anything more than the text is kept alongside it, as JSON. */
+	if primary.Structured() {
+		structured := *primary
+		structured.Word = ""
+		structured.Definition = ""
+		pending.put("structured", key, mustMarshal(&structured))
+	} else {
+		pending.remove("structured", key)
+	}
+
+	if len(entries) > 1 {
+		pending.put("variants", key, mustMarshal(entries[1:]))
+	} else {
+		pending.remove("variants", key)
+	}
+
+	/* The word backwards, so suffixes become prefixes we can scan for.
+	 * Reversing is one to one, so this doesn't need to be a list. */
+	pending.put("suffix", reverseString(key), key)
+
+	for _, el := range this.postings(key) {
+		pending.post(el.namespace, el.key, key)
+	}
+
+	/* And the words of the definitions themselves. */
+	this.stageFullText(pending, key, fullText(entries))
+}
+
+/* Get `value` as JSON. There's nothing in a Definition JSON can't write,
+ * so this can't go wrong. */
+func mustMarshal(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+/* Stage removing the headword `word` (see DeleteDefinition), returning
+ * false if there's nothing to remove. The caller has to hold the write
+ * lock. */
+func (this *LevelDBDatabase) stageDeleteWord(pending *pendingWrite, word string) bool {
+	key := this.key(word)
+	entries := this.entries(pending, key)
+	if len(entries) == 0 {
+		return false
+	}
+
+	remaining := []*dictd.Definition{}
+	for _, el := range entries {
+		if el.Word != word {
+			remaining = append(remaining, el)
+		}
+	}
+	if len(remaining) == 0 || len(remaining) == len(entries) {
+		this.stageDelete(pending, key)
+		return true
+	}
+
+	this.stageUnindex(pending, key)
+	this.stageEntries(pending, key, remaining)
+	return true
+}
+
+/* Stage removing everything under `key`, and all of its postings, into
+ * `pending`. The caller has to hold the write lock. */
+func (this *LevelDBDatabase) stageDelete(pending *pendingWrite, key string) {
+	this.stageUnindex(pending, key)
+
+	pending.remove("", key)
+	pending.remove("headword", key)
+	pending.remove("structured", key)
+	pending.remove("variants", key)
+	pending.remove("frequency", key)
+}
+
+/* Stage pulling `key` out of every index it's in, into `pending`. The
+ * caller has to hold the write lock. */
+func (this *LevelDBDatabase) stageUnindex(pending *pendingWrite, key string) {
+	for _, el := range this.postings(key) {
+		pending.unpost(el.namespace, el.key, key)
+	}
+	this.stageFullTextDelete(pending, key)
+	pending.remove("suffix", reverseString(key))
+}
+
+/* The text to index `entries` under in the full text index: all of their
+ * definitions. */
+func fullText(entries []*dictd.Definition) string {
+	texts := []string{}
+	for _, el := range entries {
+		texts = append(texts, el.Body())
+	}
+	return strings.Join(texts, "\n")
+}
+
+/* A spot in one of the index lists. */
+type posting struct {
+	namespace string
+	key       string
+}
+
+/* Get every index list (other than the full text index, which depends on
+ * the definition) the word under `word` belongs in. */
+func (this *LevelDBDatabase) postings(word string) (ret []posting) {
+	add := func(namespace string, key string) {
+		ret = append(ret, posting{namespace: namespace, key: key})
+	}
+
+	/* Right, now let's build up indexes on the word */
 
 	/* Hilarious. */
-	this.writeIndex("anagram", sortString(word), word)
+	add("anagram", sortString(word))
 
 	/* Right, now let's build up some indexes */
-	this.writeIndex("soundex", jellyfish.Soundex(word), word)
+	add("soundex", jellyfish.Soundex(word))
 
-	if len(word) > 2 { /* Fixme? */
+	if word != "" {
 		metaWords := jellyfish.Metaphone(word)
 
 		/* FO BA BAR BAZ */
 		for _, el := range strings.Split(metaWords, " ") {
-			this.writeIndex("metaphone", el, word)
+			add("metaphone", el)
+		}
+
+		/* Both keys, so a query matching either way of saying it hits */
+		primary, alternate := doubleMetaphone(word)
+		add("dmetaphone", primary)
+		if alternate != primary {
+			add("dmetaphone", alternate)
 		}
+
+		add("nysiis", jellyfish.Nysiis(word))
+		add("mra", jellyfish.MatchRatingCodex(word))
+	}
+
+	/* Trigrams, so we can narrow down substring searches. */
+	for _, el := range ngrams(word, 3) {
+		add("trigram", el)
+	}
+
+	/* Each word of the headword on its own. */
+	for _, el := range tokens(word) {
+		add("word", el)
 	}
 
+	/* What's left once the inflection is gone, so "running" and "runs"
+	 * can find "run". */
+	add("stem", this.stem(word))
+
+	/* Every way of deleting up to maxIndexedDistance characters, for
+	 * Levenshtein matching. */
+	for _, el := range deletes(word, maxIndexedDistance) {
+		add("deletes", el)
+	}
+	return
 }
 
 /*  MATCHERS  */
 
+/* Find Levenshtein matches using the "deletes" index (the SymSpell trick).
+ *
+ * If two words are within `threshold` edits of each other, then deleting
+ * at most `threshold` characters from each will land them both on some
+ * common string. We stored every such deletion of every word when we wrote
+ * it, so we only need to work out the deletions of the query, look each one
+ * up, and check the distance on the (small) pile of candidates we get back.
+ *
+ * If the database doesn't have the index (or not deep enough), we fall
+ * back to scanning every word. */
+func (this *LevelDBDatabase) matchLevenshtein(query string, threshold int) (ret []string) {
+	value, err := this.get("meta", "deletes")
+	if err != nil {
+		return this.scanLevenshtein(query, threshold)
+	}
+	if indexed, err := strconv.Atoi(value); err != nil || indexed < threshold {
+		return this.scanLevenshtein(query, threshold)
+	}
+
+	seen := map[string]bool{}
+	for _, el := range deletes(query, threshold) {
+		for _, candidate := range this.matchFromIndex("deletes", el) {
+			if candidate == "" || seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+			if jellyfish.Levenshtein(query, candidate) <= threshold {
+				ret = append(ret, candidate)
+			}
+		}
+	}
+	return
+}
+
+/* Get every (unique) string you can make by deleting at most `distance`
+ * characters from `word`, including `word` itself. */
+func deletes(word string, distance int) []string {
+	seen := map[string]bool{word: true}
+	ret := []string{word}
+	current := []string{word}
+
+	for i := 0; i < distance; i++ {
+		next := []string{}
+		for _, el := range current {
+			runes := []rune(el)
+			for j := range runes {
+				variant := string(runes[:j]) + string(runes[j+1:])
+				if seen[variant] {
+					continue
+				}
+				seen[variant] = true
+				ret = append(ret, variant)
+				next = append(next, variant)
+			}
+		}
+		current = next
+	}
+	return ret
+}
+
 /* Scan the index for Levenshtein matches. Since we need the target string
  * and the query string, this requires an O(n) scan of the index. This kinda
- * sucks. This might actually be the least performant MATCH algorithm. */
+ * sucks. This might actually be the least performant MATCH algorithm, so
+ * it's only used when there's no "deletes" index to use. */
 func (this *LevelDBDatabase) scanLevenshtein(query string, threshold int) (ret []string) {
 	iter := this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
 	for iter.Next() {
 		key := string(iter.Key())[1:]
 		distance := jellyfish.Levenshtein(query, key)
 		if distance <= threshold {
-			/* XXX: Return ordered by distance? */
 			ret = append(ret, key)
 		}
 	}
@@ -276,6 +924,110 @@ func (this *LevelDBDatabase) scanPrefix(query string) (ret []string) {
 	return
 }
 
+/* Scan for keys matching the regular expression `pattern`. If the pattern
+ * is anchored to a literal prefix, we only need to look at the keys with
+ * that prefix, otherwise it's a full O(n) scan. */
+func (this *LevelDBDatabase) scanPattern(pattern string) (ret []string) {
+	re, prefix, err := compilePattern(pattern, this.stripDiacritics)
+	if err != nil {
+		log.Printf("Bad pattern %q: %s", pattern, err)
+		return
+	}
+
+	iter := this.db.NewIterator(util.BytesPrefix([]byte("\n"+prefix)), nil)
+	for iter.Next() {
+		key := string(iter.Key())[1:]
+		if re.MatchString(key) {
+			ret = append(ret, key)
+		}
+	}
+	iter.Release()
+	return
+}
+
+/* Scan the suffix index for words ending in `query`. The suffix index has
+ * each word written backwards, so this is just a prefix scan on the
+ * reversed query. */
+func (this *LevelDBDatabase) scanSuffix(query string) (ret []string) {
+	query = "suffix\n" + reverseString(query)
+
+	iter := this.db.NewIterator(util.BytesPrefix([]byte(query)), nil)
+	for iter.Next() {
+		ret = append(ret, string(iter.Value()))
+	}
+	iter.Release()
+	return
+}
+
+/* Find words containing `query` anywhere. Every trigram of the query has
+ * to be in the word, so we intersect the trigram postings to get our
+ * candidates, and then check them properly. Queries too short to have a
+ * trigram get a full scan. */
+func (this *LevelDBDatabase) matchSubstring(query string) (ret []string) {
+	grams := ngrams(query, 3)
+	if len(grams) == 0 {
+		iter := this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
+		for iter.Next() {
+			key := string(iter.Key())[1:]
+			if strings.Contains(key, query) {
+				ret = append(ret, key)
+			}
+		}
+		iter.Release()
+		return
+	}
+
+	candidates := this.matchFromIndex("trigram", grams[0])
+	for _, gram := range grams[1:] {
+		if len(candidates) == 0 {
+			return
+		}
+		candidates = intersect(candidates, this.matchFromIndex("trigram", gram))
+	}
+
+	for _, el := range candidates {
+		if el != "" && strings.Contains(el, query) {
+			ret = append(ret, el)
+		}
+	}
+	return
+}
+
+/* Find headwords that contain each word of `query` as a word of their
+ * own, using the token index. */
+func (this *LevelDBDatabase) matchWord(query string) (ret []string) {
+	words := tokens(query)
+	if len(words) == 0 {
+		return
+	}
+
+	ret = this.matchFromIndex("word", words[0])
+	for _, el := range words[1:] {
+		ret = intersect(ret, this.matchFromIndex("word", el))
+	}
+	return
+}
+
+/* Find other inflections of `query`, or the word it's an inflection of,
+ * by looking up its stem. */
+func (this *LevelDBDatabase) matchStem(query string) []string {
+	return this.matchFromIndex("stem", this.stem(query))
+}
+
+/* Get the strings in both `a` and `b`, in the order they're in `a`. */
+func intersect(a []string, b []string) (ret []string) {
+	others := map[string]bool{}
+	for _, el := range b {
+		others[el] = true
+	}
+	for _, el := range a {
+		if others[el] {
+			ret = append(ret, el)
+		}
+	}
+	return
+}
+
 /* Given a precomputed index and a key, do a lookup of the "array" we're storing
  * in LevelDB. Return a list of strings that are hits for that target. */
 func (this *LevelDBDatabase) matchFromIndex(namespace string, key string) (ret []string) {
@@ -296,22 +1048,23 @@ func (this *LevelDBDatabase) matchSoundex(query string) (ret []string) {
 	return this.matchFromIndex("soundex", jellyfish.Soundex(query))
 }
 
+/* Internal Double Metaphone matcher. Words match if any of their keys
+ * match any of ours. */
+func (this *LevelDBDatabase) matchDoubleMetaphone(query string) (ret []string) {
+	primary, alternate := doubleMetaphone(query)
+	ret = this.matchFromIndex("dmetaphone", primary)
+	if alternate != primary {
+		ret = append(ret, this.matchFromIndex("dmetaphone", alternate)...)
+	}
+	return
+}
+
 /* Internal metaphone matcher. */
 func (this *LevelDBDatabase) matchMetaphone(query string) (ret []string) {
 	meta := jellyfish.Metaphone(query)
 	for _, el := range strings.Split(meta, " ") {
 		ret = append(ret, this.matchFromIndex("metaphone", el)...)
 	}
-
-	/* right, so ret may have multiples */
-	ordering := map[string]int{}
-	for _, el := range ret {
-		ordering[el] = 0 /* update this to count / sort */
-	}
-
-	r := []string{}
-	for k, _ := range ordering {
-		r = append(ret, k)
-	}
-	return r
+	/* ret may have multiples, but rank will sort that out. */
+	return
 }
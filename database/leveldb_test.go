@@ -0,0 +1,75 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestDeletesDistanceOne(t *testing.T) {
+	variants := deletes("abc", 1)
+
+	if len(variants) != 4 {
+		t.Errorf("Bad variant count out - didn't get 4")
+	}
+
+	for _, el := range []string{"abc", "bc", "ac", "ab"} {
+		if !contains(variants, el) {
+			t.Errorf("Missing deletion " + el)
+		}
+	}
+}
+
+func TestDeletesDistanceTwo(t *testing.T) {
+	variants := deletes("aab", 2)
+
+	/* aab, ab, aa, a, b */
+	if len(variants) != 5 {
+		t.Errorf("Bad variant count out - didn't get 5")
+	}
+}
+
+func TestDeletesUnicode(t *testing.T) {
+	if !contains(deletes("café", 1), "caf") {
+		t.Errorf("Deleted a byte rather than a character")
+	}
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, el := range haystack {
+		if el == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReverseString(t *testing.T) {
+	if reverseString("café") != "éfac" {
+		t.Errorf("Bad reversing")
+	}
+}
+
+func TestNgrams(t *testing.T) {
+	grams := ngrams("graphgraph", 3)
+
+	/* gra rap aph phg hgr */
+	if len(grams) != 5 {
+		t.Errorf("Bad trigram count out - didn't get 5")
+	}
+
+	if len(ngrams("ab", 3)) != 0 {
+		t.Errorf("Got trigrams out of a two letter word")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	words := tokens("ad hoc committee, ad-hoc can't")
+
+	/* ad hoc committee can't */
+	if len(words) != 4 {
+		t.Errorf("Bad token count out - didn't get 4")
+	}
+
+	if !contains(words, "can't") {
+		t.Errorf("Bad apostrophe handling")
+	}
+}
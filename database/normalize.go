@@ -0,0 +1,74 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* normalize.go - lookup key normalization for database backends.
+ *
+ * Headwords are stored (and displayed) exactly as the dictionary wrote
+ * them, but we look them up by a normalized key, so that "NASA", "nasa"
+ * and "Nasa" all find the same entries, and so that a precomposed "é"
+ * and an "e" followed by a combining acute are the same thing. */
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+/* Given a headword (or an incoming query) `word`, return the key we store
+ * and search for it under. The key is NFKC normalized and case folded, with
+ * runs of whitespace collapsed into a single space (so it can never contain
+ * the "\n" we use for namespacing).
+ *
+ * If `stripDiacritics` is set, combining marks are dropped as well, so that
+ * "café" and "cafe" share a key. */
+func NormalizeKey(word string, stripDiacritics bool) string {
+	key := foldText(word, stripDiacritics)
+	return strings.Join(strings.Fields(key), " ")
+}
+
+/* NFKC normalize and case fold `text`, dropping combining marks if
+ * `stripDiacritics` is set, like NormalizeKey, but leaving whitespace
+ * alone. Pattern literals go through this to match the keys. */
+func foldText(text string, stripDiacritics bool) string {
+	text = norm.NFKC.String(text)
+	text = cases.Fold().String(text)
+
+	if stripDiacritics {
+		stripper := transform.Chain(
+			norm.NFD,
+			runes.Remove(runes.In(unicode.Mn)),
+			norm.NFC,
+		)
+		if stripped, _, err := transform.String(stripper, text); err == nil {
+			text = stripped
+		}
+	}
+
+	/* Case folding can leave us denormalized ("ǰ" folds into a j and a
+	 * combining caron), so run it through NFKC once more on the way out. */
+	return norm.NFKC.String(text)
+}
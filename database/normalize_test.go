@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestNormalizeKeyCase(t *testing.T) {
+	if NormalizeKey("NASA", false) != "nasa" {
+		t.Errorf("Bad case folding")
+	}
+
+	if NormalizeKey("Straße", false) != "strasse" {
+		t.Errorf("Bad full case folding")
+	}
+}
+
+func TestNormalizeKeyComposition(t *testing.T) {
+	if NormalizeKey("café", false) != "café" {
+		t.Errorf("Bad composition")
+	}
+
+	if NormalizeKey("ﬁne", false) != "fine" {
+		t.Errorf("Bad compatibility decomposition")
+	}
+}
+
+func TestNormalizeKeyDiacritics(t *testing.T) {
+	if NormalizeKey("Café", true) != "cafe" {
+		t.Errorf("Bad diacritic stripping")
+	}
+
+	if NormalizeKey("Café", false) != "café" {
+		t.Errorf("Stripped diacritics when we shouldn't have")
+	}
+}
+
+func TestNormalizeKeyWhitespace(t *testing.T) {
+	if NormalizeKey("  hacker \t\n ethic ", false) != "hacker ethic" {
+		t.Errorf("Bad whitespace handling")
+	}
+}
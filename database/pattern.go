@@ -0,0 +1,267 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* pattern.go - regular expression and glob support for MATCH.
+ *
+ * Patterns have to be checked against every key they could match, so the
+ * trick here is to work out the literal text every match has to start with
+ * (if the pattern is anchored), and only walk that part of the keyspace.
+ *
+ * Go's regexp package is RE2, so there's no catastrophic backtracking to
+ * worry about, but we still refuse patterns that are silly long, or that
+ * compile down to a silly large program. */
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+const (
+	maxPatternLength       = 256
+	maxPatternInstructions = 10000
+)
+
+/* Given an (extended) regular expression `pattern`, compile it for
+ * matching against our keys, and figure out the literal prefix every match
+ * has to start with, if there is one. Literal text in the pattern is
+ * folded like the keys were (see NormalizeKey), so "^Straße" finds
+ * "strasse", and with `stripDiacritics`, "^café" finds "cafe". */
+func compilePattern(pattern string, stripDiacritics bool) (*regexp.Regexp, string, error) {
+	if len(pattern) > maxPatternLength {
+		return nil, "", errors.New("Pattern is too long")
+	}
+
+	flags := syntax.Perl | syntax.FoldCase
+	tree, err := syntax.Parse(pattern, flags)
+	if err != nil {
+		return nil, "", err
+	}
+	foldLiterals(tree, stripDiacritics)
+
+	prog, err := syntax.Compile(tree.Simplify())
+	if err != nil {
+		return nil, "", err
+	}
+	if len(prog.Inst) > maxPatternInstructions {
+		return nil, "", errors.New("Pattern is too complex")
+	}
+
+	re, err := regexp.Compile(tree.String())
+	if err != nil {
+		return nil, "", err
+	}
+
+	return re, literalPrefix(tree), nil
+}
+
+/* If the parsed expression `tree` is anchored to the start of the text,
+ * get the literal text it starts with. */
+func literalPrefix(tree *syntax.Regexp) string {
+	if tree.Op != syntax.OpConcat || len(tree.Sub) < 2 {
+		return ""
+	}
+
+	switch tree.Sub[0].Op {
+	case syntax.OpBeginText, syntax.OpBeginLine:
+	default:
+		return ""
+	}
+
+	prefix := ""
+	for _, sub := range tree.Sub[1:] {
+		if sub.Op != syntax.OpLiteral {
+			break
+		}
+		prefix += string(sub.Rune)
+	}
+	return prefix
+}
+
+/* Fold the literal text in the parsed expression `tree` the way keys are
+ * (see foldText). */
+func foldLiterals(tree *syntax.Regexp, stripDiacritics bool) {
+	if tree.Op == syntax.OpLiteral {
+		tree.Rune = []rune(foldText(string(tree.Rune), stripDiacritics))
+	}
+	for _, sub := range tree.Sub {
+		foldLiterals(sub, stripDiacritics)
+	}
+}
+
+/* Turn a POSIX basic regular expression `basic` (with the GNU `\+`, `\?`
+ * and `\|` extensions, like dictd's own "regexp" strategy) into the
+ * extended syntax compilePattern takes. In a basic expression, `\(`,
+ * `\)`, `\{` and `\}` group and count, and plain `(`, `)`, `{`, `}`, `+`,
+ * `?` and `|` are just characters. So is `*` at the very start of an
+ * expression, `^` anywhere but the start, and `$` anywhere but the end.
+ * Backslashes in bracket expressions are just backslashes, too. */
+func basicToPattern(basic string) string {
+	runes := []rune(basic)
+	pattern := ""
+
+	/* At the start of an expression (or group, or alternative), where `*`
+	 * is a literal and `^` is an anchor. */
+	start := true
+
+	/* At the end of one, where `$` is an anchor. */
+	atEnd := func(i int) bool {
+		rest := string(runes[i+1:])
+		return rest == "" || strings.HasPrefix(rest, `\)`) || strings.HasPrefix(rest, `\|`)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		el := runes[i]
+		switch {
+		case el == '\\' && i+1 < len(runes):
+			i++
+			switch runes[i] {
+			case '(', '|':
+				pattern += string(runes[i])
+				start = true
+				continue
+			case ')', '{', '}', '+', '?':
+				pattern += string(runes[i])
+			default:
+				pattern += `\` + string(runes[i])
+			}
+		case el == '^' && start:
+			pattern += "^"
+			continue
+		case el == '$' && atEnd(i):
+			pattern += "$"
+		case el == '*' && start:
+			pattern += `\*`
+		case strings.ContainsRune("(){}+?|^$", el):
+			pattern += `\` + string(el)
+		case el == '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '^' {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++ /* "[]]" is a class with just "]" in it */
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end += characterClassLength(runes[end:])
+			}
+			if end >= len(runes) {
+				/* No closing bracket, so it's just a bracket. */
+				pattern += `\[`
+				break
+			}
+
+			class := runes[i+1 : end]
+			pattern += "["
+			if len(class) > 0 && class[0] == '^' {
+				pattern += "^"
+				class = class[1:]
+			}
+			for j := 0; j < len(class); {
+				if length := characterClassLength(class[j:]); length > 1 {
+					pattern += string(class[j : j+length])
+					j += length
+					continue
+				}
+				if strings.ContainsRune(`\[]`, class[j]) {
+					pattern += `\`
+				}
+				pattern += string(class[j])
+				j++
+			}
+			pattern += "]"
+			i = end
+		default:
+			pattern += string(el)
+		}
+		start = false
+	}
+
+	return pattern
+}
+
+/* If `runes` starts with a named character class, like "[:alpha:]", get
+ * how long it is, otherwise 1. */
+func characterClassLength(runes []rune) int {
+	if len(runes) < 2 || runes[0] != '[' || runes[1] != ':' {
+		return 1
+	}
+	for i := 2; i+1 < len(runes); i++ {
+		if runes[i] == ':' && runes[i+1] == ']' {
+			return i + 2
+		}
+	}
+	return 1
+}
+
+/* Turn a shell style glob `glob` (`*`, `?` and `[...]`) into an anchored
+ * regular expression that compilePattern will take. */
+func globToPattern(glob string) string {
+	runes := []rune(glob)
+	pattern := "^"
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			pattern += ".*"
+		case '?':
+			pattern += "."
+		case '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '!' {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++ /* "[]]" is a class with just "]" in it */
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				/* No closing bracket, so it's just a bracket. */
+				pattern += `\[`
+				continue
+			}
+
+			class := runes[i+1 : end]
+			pattern += "["
+			if len(class) > 0 && class[0] == '!' {
+				pattern += "^"
+				class = class[1:]
+			}
+			for _, el := range class {
+				if el == '\\' || el == '[' || el == ']' {
+					pattern += `\`
+				}
+				pattern += string(el)
+			}
+			pattern += "]"
+			i = end
+		default:
+			pattern += regexp.QuoteMeta(string(runes[i]))
+		}
+	}
+
+	return pattern + "$"
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestPatternPrefix(t *testing.T) {
+	re, prefix, err := compilePattern(`^Hack(er|ish)$`, false)
+	if err != nil {
+		t.Errorf("Error compiling pattern")
+		return
+	}
+
+	if prefix != "hack" {
+		t.Errorf("Bad literal prefix %q", prefix)
+	}
+
+	if !re.MatchString("hacker") || re.MatchString("hacked") {
+		t.Errorf("Bad pattern matching")
+	}
+}
+
+func TestPatternUnanchored(t *testing.T) {
+	_, prefix, err := compilePattern(`graph`, false)
+	if err != nil {
+		t.Errorf("Error compiling pattern")
+	}
+
+	if prefix != "" {
+		t.Errorf("Unanchored pattern got a prefix")
+	}
+}
+
+func TestPatternTooComplex(t *testing.T) {
+	if _, _, err := compilePattern(`(a{1,1000}){1,1000}`, false); err == nil {
+		t.Errorf("Accepted a pathological pattern")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	re, prefix, err := compilePattern(globToPattern("hack*[!s]?"), false)
+	if err != nil {
+		t.Errorf("Error compiling glob")
+		return
+	}
+
+	if prefix != "hack" {
+		t.Errorf("Bad glob literal prefix %q", prefix)
+	}
+
+	if !re.MatchString("hacker") || re.MatchString("hackss") {
+		t.Errorf("Bad glob matching")
+	}
+}
+
+func TestGlobEscaping(t *testing.T) {
+	re, _, err := compilePattern(globToPattern("a.b["), false)
+	if err != nil {
+		t.Errorf("Error compiling glob")
+		return
+	}
+
+	if !re.MatchString("a.b[") || re.MatchString("axb[") {
+		t.Errorf("Bad glob escaping")
+	}
+}
+
+func TestBasicPattern(t *testing.T) {
+	for basic, extended := range map[string]string{
+		`^\(ab\)\{2\}$`:   `^(ab){2}$`,
+		`a+b?(c)|d`:       `a\+b\?\(c\)\|d`,
+		`a\+\|b`:          `a+|b`,
+		`*a^b$c$`:         `\*a\^b\$c$`,
+		`[]a\[:alpha:]]x`: `[\]a\\[:alpha:]]x`,
+		`\(*a\)`:          `(\*a)`,
+		`[^]]`:            `[^\]]`,
+	} {
+		if pattern := basicToPattern(basic); pattern != extended {
+			t.Errorf("%s came out as %s, not %s", basic, pattern, extended)
+		}
+	}
+
+	re, prefix, err := compilePattern(basicToPattern(`^hack\(er\|ish\)$`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "hack" || !re.MatchString("hackish") || re.MatchString("hack(er|ish)") {
+		t.Errorf("Bad basic pattern matching")
+	}
+}
+
+func TestPatternFolding(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.SetStripDiacritics(true)
+	db.WriteDefinition("Straße", "A street.")
+	db.WriteDefinition("Café", "Coffee.")
+
+	for strategy, pattern := range map[string]string{
+		"re":     `^STRAßE$`,
+		"regexp": `^straße$`,
+		"glob":   `Stra*`,
+	} {
+		defs := db.Match("test", pattern, strategy)
+		if len(defs) != 1 || defs[0].Word != "Straße" {
+			t.Errorf("%s %s didn't find Straße", strategy, pattern)
+		}
+	}
+
+	defs := db.Match("test", `^café`, "re")
+	if len(defs) != 1 || defs[0].Word != "Café" {
+		t.Errorf("^café didn't find Café with diacritics stripped")
+	}
+}
@@ -0,0 +1,278 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* rank.go - scoring and ordering of MATCH results.
+ *
+ * Every MATCH strategy hands back a pile of candidate keys in whatever
+ * order the index had them in. Before we send them to the user, we score
+ * each candidate against the query, so the most likely suggestion is at the
+ * top of the list rather than wherever LevelDB happened to put it.
+ *
+ * The score is a weighted blend of:
+ *
+ *  - Levenshtein distance (normalized by length)
+ *  - Jaro-Winkler similarity (which likes shared prefixes)
+ *  - Phonetic key agreement (Soundex and Metaphone)
+ *  - How often the word is used, if the database knows that ("frequency\nkey")
+ *
+ * Looking up how often a word is used is a read per word, so that's only
+ * done for the best `rankFrequencyLimit` (or maximum result count, if
+ * that's more) candidates going by the rest of the score. Frequency is
+ * only a small part of the score, so it's there to reorder the front of
+ * the list, not to drag something up out of the long tail. */
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/jamesturk/go-jellyfish"
+)
+
+const (
+	editWeight      = 0.35
+	jaroWeight      = 0.35
+	phoneticWeight  = 0.2
+	frequencyWeight = 0.1
+
+	rankFrequencyLimit = 100
+)
+
+/* A candidate key, along with how good of a match we think it is. */
+type scoredKey struct {
+	key   string
+	score float64
+}
+
+/* Given the (normalized) query `query` and candidate keys `keys`, drop any
+ * duplicates, and return the keys ordered best-first. If the database has a
+ * maximum result count, only that many keys are returned. */
+func (this *LevelDBDatabase) rank(query string, keys []string) []string {
+	seen := map[string]bool{}
+	candidates := []scoredKey{}
+
+	querySoundex := jellyfish.Soundex(query)
+	queryMetaphone := jellyfish.Metaphone(query)
+
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		score := editWeight*editSimilarity(query, key) +
+			jaroWeight*jaroWinkler(query, key)
+
+		if querySoundex == jellyfish.Soundex(key) {
+			score += phoneticWeight / 2
+		}
+		if queryMetaphone == jellyfish.Metaphone(key) {
+			score += phoneticWeight / 2
+		}
+
+		candidates = append(candidates, scoredKey{key: key, score: score})
+	}
+
+	sort.Sort(byScore(candidates))
+
+	head := rankFrequencyLimit
+	if this.maxResults > head {
+		head = this.maxResults
+	}
+	if head > len(candidates) {
+		head = len(candidates)
+	}
+	this.rankByFrequency(candidates[:head])
+
+	if this.maxResults > 0 && len(candidates) > this.maxResults {
+		candidates = candidates[:this.maxResults]
+	}
+
+	ret := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ret[i] = candidate.key
+	}
+	return ret
+}
+
+/* Add how often each of `candidates` is used to its score, relative to
+ * the most used of them, and sort them again. */
+func (this *LevelDBDatabase) rankByFrequency(candidates []scoredKey) {
+	frequencies := make([]int, len(candidates))
+	maxFrequency := 0
+	for i, candidate := range candidates {
+		frequencies[i] = this.frequency(candidate.key)
+		if frequencies[i] > maxFrequency {
+			maxFrequency = frequencies[i]
+		}
+	}
+	if maxFrequency == 0 {
+		return
+	}
+
+	for i := range candidates {
+		candidates[i].score += frequencyWeight *
+			math.Log1p(float64(frequencies[i])) /
+			math.Log1p(float64(maxFrequency))
+	}
+	sort.Sort(byScore(candidates))
+}
+
+/* Given keys `keys` that are already in a sensible order, drop any
+ * duplicates, and cut them down to the maximum result count. */
+func (this *LevelDBDatabase) limit(keys []string) []string {
+	seen := map[string]bool{}
+	ret := []string{}
+
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		if this.maxResults > 0 && len(ret) >= this.maxResults {
+			break
+		}
+		seen[key] = true
+		ret = append(ret, key)
+	}
+	return ret
+}
+
+/* Sort scored keys best-first, falling back to the key itself so the
+ * output is stable between requests. */
+type byScore []scoredKey
+
+func (s byScore) Len() int      { return len(s) }
+func (s byScore) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byScore) Less(i, j int) bool {
+	if s[i].score != s[j].score {
+		return s[i].score > s[j].score
+	}
+	return s[i].key < s[j].key
+}
+
+/* Get how often the word under `key` is used, or 0 if we've no idea. */
+func (this *LevelDBDatabase) frequency(key string) int {
+	value, err := this.get("frequency", key)
+	if err != nil {
+		return 0
+	}
+	frequency, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return frequency
+}
+
+/* Levenshtein distance, flipped around into a 0 (nothing alike) to 1
+ * (identical) similarity so it can be blended with the others. */
+func editSimilarity(a string, b string) float64 {
+	longest := utf8.RuneCountInString(a)
+	if length := utf8.RuneCountInString(b); length > longest {
+		longest = length
+	}
+	if longest == 0 {
+		return 1
+	}
+	distance := jellyfish.Levenshtein(a, b)
+	return 1 - float64(distance)/float64(longest)
+}
+
+/* Jaro-Winkler similarity of `a` and `b`, from 0 (nothing alike) to 1
+ * (identical). Strings that share a prefix get a boost. */
+func jaroWinkler(a string, b string) float64 {
+	s1 := []rune(a)
+	s2 := []rune(b)
+
+	if len(s1) == 0 && len(s2) == 0 {
+		return 1
+	}
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+
+	window := len(s1)
+	if len(s2) > window {
+		window = len(s2)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+
+	for i := range s1 {
+		start := i - window
+		if start < 0 {
+			start = 0
+		}
+		end := i + window + 1
+		if end > len(s2) {
+			end = len(s2)
+		}
+		for j := start; j < end; j++ {
+			if matched2[j] || s1[i] != s2[j] {
+				continue
+			}
+			matched1[i] = true
+			matched2[j] = true
+			matches++
+			break
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) +
+		m/float64(len(s2)) +
+		(m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < len(s1) && prefix < len(s2) &&
+		s1[prefix] == s2[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
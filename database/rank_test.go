@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestJaroWinklerIdentical(t *testing.T) {
+	if jaroWinkler("hacker", "hacker") != 1 {
+		t.Errorf("Identical strings aren't a perfect match")
+	}
+}
+
+func TestJaroWinklerDisjoint(t *testing.T) {
+	if jaroWinkler("abc", "xyz") != 0 {
+		t.Errorf("Disjoint strings matched")
+	}
+}
+
+func TestJaroWinklerKnown(t *testing.T) {
+	score := jaroWinkler("martha", "marhta")
+	if score < 0.9610 || score > 0.9612 {
+		t.Errorf("Bad Jaro-Winkler score for martha/marhta: %f", score)
+	}
+}
+
+func TestJaroWinklerPrefix(t *testing.T) {
+	if jaroWinkler("hacker", "hackers") <= jaroWinkler("hacker", "shacker") {
+		t.Errorf("Shared prefix didn't get a boost")
+	}
+}
+
+func TestRankFrequency(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	/* "bat" and "hat" are just as close to "cat", so without a frequency
+	 * they'd go alphabetically. */
+	loader := NewBulkLoader(db)
+	loader.WriteFrequency("Hat", 100)
+	loader.WriteFrequency("bat", 2)
+	if err := loader.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	ranked := db.rank("cat", []string{"bat", "hat"})
+	if len(ranked) != 2 || ranked[0] != "hat" {
+		t.Errorf("Frequency didn't count for anything: %v", ranked)
+	}
+}
@@ -32,12 +32,17 @@ func (this *UrbanDictionaryDatabase) Define(name string, query string) (definiti
 	}
 
 	for _, el := range response.Results {
-		definitions = append(definitions, &dictd.Definition{
+		def := &dictd.Definition{
 			Word:             el.Word,
 			Definition:       el.Definition,
 			DictDatabase:     this,
 			DictDatabaseName: name,
-		})
+			Source:           el.Permalink,
+		}
+		if el.Example != "" {
+			def.Examples = []string{el.Example}
+		}
+		definitions = append(definitions, def)
 		log.Printf("%s\n", el.Word)
 	}
 	return
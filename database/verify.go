@@ -0,0 +1,342 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package database
+
+/* verify.go - consistency checks and index rebuilds for LevelDB databases.
+ *
+ * The word namespace ("\nkey") is the source of truth; everything else is
+ * derived from it. Databases written by older versions, by buggy loaders,
+ * or with a different language or key normalization than they're read
+ * with, can end up with indexes that don't agree with it. Verify tells
+ * you how bad it is, and Reindex throws the indexes away and builds them
+ * again from the words. */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+/* Every namespace Reindex knows how to rebuild. Other than "suffix" and
+ * "fulltext" (which also covers "terms"), these are all lists of keys. */
+var IndexNamespaces = []string{
+	"anagram",
+	"soundex",
+	"metaphone",
+	"dmetaphone",
+	"nysiis",
+	"mra",
+	"trigram",
+	"word",
+	"stem",
+	"deletes",
+	"suffix",
+	"fulltext",
+}
+
+/* A single posting that's wrong, one way or the other. */
+type IndexProblem struct {
+	Namespace string
+	Key       string
+	Word      string
+}
+
+func (this IndexProblem) String() string {
+	return fmt.Sprintf("%s[%q] -> %q", this.Namespace, this.Key, this.Word)
+}
+
+/* VerifyReport is everything Verify found. Orphans are postings pointing
+ * at words that aren't defined, and Missing are postings a word should
+ * have, but doesn't. */
+type VerifyReport struct {
+	Words   int
+	Orphans []IndexProblem
+	Missing []IndexProblem
+}
+
+/* Check to see if the report found anything wrong. */
+func (this *VerifyReport) OK() bool {
+	return len(this.Orphans) == 0 && len(this.Missing) == 0
+}
+
+/* Turn the list of namespaces `namespaces` into a set, where an empty list
+ * means all of them. */
+func namespaceSet(namespaces []string) (map[string]bool, error) {
+	known := map[string]bool{}
+	for _, el := range IndexNamespaces {
+		known[el] = true
+	}
+
+	if len(namespaces) == 0 {
+		return known, nil
+	}
+
+	ret := map[string]bool{}
+	for _, el := range namespaces {
+		if !known[el] {
+			return nil, fmt.Errorf("Unknown index namespace %q", el)
+		}
+		ret[el] = true
+	}
+	return ret, nil
+}
+
+/* Check every posting in the index namespaces `namespaces` (or all of
+ * them, if that's empty) points to a word that exists, and every word
+ * has all of the postings it should. */
+func (this *LevelDBDatabase) Verify(namespaces []string) (*VerifyReport, error) {
+	selected, err := namespaceSet(namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	report := VerifyReport{}
+	words := map[string]bool{}
+
+	iter := this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
+	for iter.Next() {
+		words[string(iter.Key())[1:]] = true
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	report.Words = len(words)
+
+	/* First, the orphans. */
+	for namespace := range selected {
+		iter := this.db.NewIterator(util.BytesPrefix([]byte(namespace+"\n")), nil)
+		for iter.Next() {
+			key := string(iter.Key())[len(namespace)+1:]
+			value := string(iter.Value())
+
+			targets := strings.Split(value, "\n")
+			if namespace == "suffix" {
+				targets = []string{value}
+			}
+
+			for _, el := range targets {
+				if el != "" && !words[el] {
+					report.Orphans = append(report.Orphans, IndexProblem{
+						Namespace: namespace,
+						Key:       key,
+						Word:      el,
+					})
+				}
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	if selected["fulltext"] {
+		iter := this.db.NewIterator(util.BytesPrefix([]byte("terms\n")), nil)
+		for iter.Next() {
+			key := string(iter.Key())[len("terms\n"):]
+			if !words[key] {
+				report.Orphans = append(report.Orphans, IndexProblem{
+					Namespace: "terms",
+					Key:       key,
+					Word:      key,
+				})
+			}
+		}
+		iter.Release()
+	}
+
+	/* Now, everything that should be there, but isn't. */
+	missing := func(namespace string, key string, word string) {
+		report.Missing = append(report.Missing, IndexProblem{
+			Namespace: namespace,
+			Key:       key,
+			Word:      word,
+		})
+	}
+
+	iter = this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		word := string(iter.Key())[1:]
+
+		for _, el := range this.postings(word) {
+			if selected[el.namespace] && !this.listContains(el.namespace, el.key, word) {
+				missing(el.namespace, el.key, word)
+			}
+		}
+
+		if selected["suffix"] {
+			if value, err := this.get("suffix", reverseString(word)); err != nil || value != word {
+				missing("suffix", reverseString(word), word)
+			}
+		}
+
+		if selected["fulltext"] {
+			if _, err := this.get("terms", word); err != nil {
+				missing("terms", word, word)
+			}
+			seen := map[string]bool{}
+			for _, term := range this.analyze(fullText(this.entries(nil, word))) {
+				if seen[term] {
+					continue
+				}
+				seen[term] = true
+				if !this.listContains("fulltext", term, word) {
+					missing("fulltext", term, word)
+				}
+			}
+		}
+	}
+
+	return &report, iter.Error()
+}
+
+/* Check to see if `word` is in the index list `key` in `namespace`. */
+func (this *LevelDBDatabase) listContains(namespace string, key string, word string) bool {
+	for _, el := range this.matchFromIndex(namespace, key) {
+		if el == word {
+			return true
+		}
+	}
+	return false
+}
+
+/* Throw away the index namespaces `namespaces` (or all of them, if that's
+ * empty), and build them again from the word namespace. This holds the
+ * write lock the whole time, so writes will wait until it's done. */
+func (this *LevelDBDatabase) Reindex(namespaces []string) error {
+	selected, err := namespaceSet(namespaces)
+	if err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	/* Clear out the old indexes. */
+	prefixes := []string{}
+	for namespace := range selected {
+		prefixes = append(prefixes, namespace+"\n")
+	}
+	if selected["fulltext"] {
+		prefixes = append(prefixes, "terms\n")
+	}
+
+	batch := new(leveldb.Batch)
+	for _, prefix := range prefixes {
+		iter := this.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+			if batch.Len() >= maxBatchSize {
+				if err := this.db.Write(batch, nil); err != nil {
+					iter.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+	if selected["fulltext"] {
+		batch.Delete([]byte("meta\nfulltext-docs"))
+		batch.Delete([]byte("meta\nfulltext-length"))
+	}
+	if err := this.db.Write(batch, nil); err != nil {
+		return err
+	}
+
+	/* And build them back up, a chunk of words at a time. */
+	pending := newPendingWrite()
+	staged := 0
+
+	iter := this.db.NewIterator(util.BytesPrefix([]byte("\n")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		word := string(iter.Key())[1:]
+
+		for _, el := range this.postings(word) {
+			if selected[el.namespace] {
+				pending.post(el.namespace, el.key, word)
+			}
+		}
+		if selected["suffix"] {
+			pending.put("suffix", reverseString(word), word)
+		}
+		if selected["fulltext"] {
+			this.stageFullText(pending, word, fullText(this.entries(nil, word)))
+		}
+
+		staged++
+		if staged >= 10000 {
+			if err := this.flush(pending); err != nil {
+				return err
+			}
+			pending = newPendingWrite()
+			staged = 0
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := this.flush(pending); err != nil {
+		return err
+	}
+
+	/* Every word has its deletions now, so we can trust the index. */
+	if selected["deletes"] {
+		this.write("meta", "deletes", strconv.Itoa(maxIndexedDistance))
+	}
+	return nil
+}
+
+/* Compact the whole LevelDB database, to reclaim the space taken up by
+ * deleted and overwritten keys (of which Reindex makes plenty). */
+func (this *LevelDBDatabase) Compact() error {
+	return this.db.CompactRange(util.Range{})
+}
+
+/* Count how many keys are in each namespace of the database, with the
+ * word namespace under "words". */
+func (this *LevelDBDatabase) Stats() (map[string]int, error) {
+	stats := map[string]int{}
+
+	iter := this.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := string(iter.Key())
+		namespace := "unknown"
+		if i := strings.Index(key, "\n"); i == 0 {
+			namespace = "words"
+		} else if i > 0 {
+			namespace = key[:i]
+		}
+		stats[namespace]++
+	}
+	return stats, iter.Error()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestVerifyClean(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteDefinition("graph", "A diagram.")
+	db.WriteDefinition("hacker ethic", "A belief.")
+
+	report, err := db.Verify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() || report.Words != 2 {
+		t.Errorf("Clean database failed verification")
+	}
+}
+
+func TestVerifyAndReindex(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	db.WriteDefinition("graph", "A diagram.")
+
+	/* Break things behind the database's back */
+	db.db.Delete([]byte("trigram\ngra"), nil)
+	db.write("word", "chart", "chart")
+
+	report, err := db.Verify([]string{"trigram", "word"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Missing) != 1 || report.Missing[0].Namespace != "trigram" {
+		t.Errorf("Didn't find the missing posting")
+	}
+
+	if len(report.Orphans) != 1 || report.Orphans[0].Word != "chart" {
+		t.Errorf("Didn't find the orphaned posting")
+	}
+
+	if err := db.Reindex(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = db.Verify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() {
+		t.Errorf("Reindexed database failed verification")
+	}
+
+	if db.counter("fulltext-docs") != 1 {
+		t.Errorf("Bad document count after a reindex")
+	}
+}
+
+func TestVerifyUnknownNamespace(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	if _, err := db.Verify([]string{"bogus"}); err == nil {
+		t.Errorf("Verified a namespace that doesn't exist")
+	}
+}
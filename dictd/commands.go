@@ -27,6 +27,7 @@ package dictd
  * handlers, the initial handshake, and the core MUST-haves */
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -43,7 +44,7 @@ func unknownCommandHandler(session *Session, command Command) {
 func handshakeHandler(session *Session) {
 	session.Connection.Writer.PrintfLine("220 %s <%s> <%s>",
 		"go-dictd",
-		"mime",
+		"mime.xtypes.xsuggest",
 		session.MsgId,
 	)
 }
@@ -51,9 +52,8 @@ func handshakeHandler(session *Session) {
 /*
  */
 func clientCommandHandler(session *Session, command Command) {
-	/* Ignore everything for now, in the future, we should be
-	 * setting the value of the message over into session.Client or
-	 * something. */
+	/* Only kept for the QueryLog, for now. */
+	session.Client = strings.Join(command.Params, " ")
 	WriteCode(session, 250, "ok")
 }
 
@@ -80,15 +80,16 @@ func showCommandHandler(session *Session, command Command) {
 			"110 %d database(s) present",
 			len(session.DictServer.allDatabases),
 		)
+		lines := []string{}
 		for _, db := range session.DictServer.allDatabases {
 			databaseBackend := session.DictServer.GetDatabase(db)
-			session.Connection.Writer.PrintfLine(
+			lines = append(lines, fmt.Sprintf(
 				"%s \"%s\"",
 				db,
 				databaseBackend.Description(db),
-			)
+			))
 		}
-		session.Connection.Writer.PrintfLine(".")
+		WriteTextBlock(session, strings.Join(lines, "\n"))
 		WriteCode(session, 250, "ok")
 		return
 	case "STRAT", "STRATEGIES":
@@ -96,10 +97,11 @@ func showCommandHandler(session *Session, command Command) {
 			"111 %d present",
 			len(session.DictServer.strats),
 		)
+		lines := []string{}
 		for name, descr := range session.DictServer.strats {
-			session.Connection.Writer.PrintfLine(`%s "%s"`, name, descr)
+			lines = append(lines, fmt.Sprintf(`%s "%s"`, name, descr))
 		}
-		session.Connection.Writer.PrintfLine(".")
+		WriteTextBlock(session, strings.Join(lines, "\n"))
 		session.Connection.Writer.PrintfLine("250 ok")
 		return
 	case "INFO":
@@ -115,12 +117,12 @@ func showCommandHandler(session *Session, command Command) {
 			WriteCode(session, 550, "invalid database")
 			return
 		}
-		WriteTextBlock(session, databaseBackend.Info(name))
+		WriteWrappedTextBlock(session, databaseBackend.Info(name))
 		WriteCode(session, 250, "ok")
 		return
 	case "SERVER":
 		WriteCode(session, 114, "server information")
-		WriteTextBlock(session, session.DictServer.Info)
+		WriteWrappedTextBlock(session, session.DictServer.Info)
 		WriteCode(session, 250, "ok")
 		return
 	}
@@ -143,12 +145,19 @@ func optionCommandHandler(session *Session, command Command) {
 
 	switch param {
 	case "MIME":
-		session.Options["MIME"] = !session.Options["MIME"]
-		if session.Options["MIME"] {
-			WriteCode(session, 250, "ok - mime I guess")
-		} else {
-			WriteCode(session, 250, "ok - no mime I guess")
+		/* Anything after MIME is our extension: the content types the
+		 * client would like definitions in. See mime.go. */
+		session.Options["MIME"] = true
+		session.Accept = []string{}
+		for _, el := range command.Params[1:] {
+			session.Accept = append(session.Accept, strings.ToLower(el))
 		}
+		WriteCode(session, 250, "ok - using MIME headers")
+		return
+	case "SUGGEST":
+		/* Our extension; see suggest.go. */
+		session.Options["SUGGEST"] = true
+		WriteCode(session, 250, "ok - suggesting matches for missing words")
 		return
 	}
 
@@ -183,7 +192,12 @@ func writeDefinition(
 		db,
 		databaseBackend.Description(db),
 	)
-	WriteTextBlock(session, definition.Definition)
+	contentType := negotiate(session, definition)
+	text := render(session, definition, contentType)
+	if contentType == TextPlain {
+		text = reflow(text, session.DictServer.Width)
+	}
+	WriteTypedTextBlock(session, contentType, text)
 }
 
 /*
@@ -205,22 +219,29 @@ func matchCommandHandler(session *Session, command Command) {
 		WriteCode(session, 550, "invalid database")
 		return
 	}
+	session.hits = len(defs)
 
 	if len(defs) == 0 {
 		WriteCode(session, 552, "no match")
 		return
 	}
 
+	writeMatches(session, defs)
+	WriteCode(session, 250, "ok")
+}
+
+/* Write the 152 list of the matches `defs`. */
+func writeMatches(session *Session, defs []*Definition) {
 	session.Connection.Writer.PrintfLine("152 %d matches found", len(defs))
+	lines := []string{}
 	for _, el := range defs {
-		session.Connection.Writer.PrintfLine(
+		lines = append(lines, fmt.Sprintf(
 			"%s \"%s\"",
 			el.DictDatabaseName,
 			el.Word,
-		)
+		))
 	}
-	session.Connection.Writer.PrintfLine(".")
-	WriteCode(session, 250, "ok")
+	WriteTextBlock(session, strings.Join(lines, "\n"))
 }
 
 /*
@@ -241,9 +262,10 @@ func defineCommandHandler(session *Session, command Command) {
 		WriteCode(session, 550, "invalid database")
 		return
 	}
+	session.hits = len(defs)
 
 	if len(defs) == 0 {
-		WriteCode(session, 552, "no match")
+		writeNoDefinition(session, database, word)
 		return
 	}
 
@@ -0,0 +1,151 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package dictd
+
+/* definition.go - turning structured Definitions into text.
+ *
+ * Classic dict clients only ever see a block of text. A Database that
+ * already has that text puts it in Definition, and we send it as is. One
+ * that only has the structure (senses, examples and so on) can leave
+ * Definition empty, and we'll write the text for it here. Either way,
+ * where it came from and when it changed go on the end. */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/* The order we write related words in; anything else comes after these,
+ * in alphabetical order. */
+var relationOrder = []string{"synonym", "hypernym", "translation", "see-also"}
+
+/* What we call each relation when writing it out. */
+var relationNames = map[string]string{
+	"synonym":     "Synonyms",
+	"hypernym":    "Broader terms",
+	"translation": "Translations",
+	"see-also":    "See also",
+}
+
+/* What to call the relation `relation` when writing it out. */
+func relationName(relation string) string {
+	if name, ok := relationNames[relation]; ok {
+		return name
+	}
+	return relation
+}
+
+/* Check to see if any of the structured fields are set. */
+func (this *Definition) Structured() bool {
+	return this.Pronunciation != "" ||
+		len(this.PartsOfSpeech) > 0 || len(this.Senses) > 0 ||
+		len(this.Examples) > 0 || this.Source != "" ||
+		len(this.Related) > 0 || this.Modified != nil
+}
+
+/* Get the body of the Definition as text: Definition, if it's set, or
+ * the structured fields written out, if not. */
+func (this *Definition) Body() string {
+	if this.Definition != "" {
+		return this.Definition
+	}
+	return this.render()
+}
+
+/* Get the Definition as text, for classic clients. */
+func (this *Definition) Text() string {
+	text := this.Body()
+
+	if footer := definitionFooter(this); len(footer) > 0 {
+		text = strings.TrimRight(text, "\r\n") + "\n\n" + strings.Join(footer, "\n")
+	}
+	return text
+}
+
+/* Where it came from and when it changed, if we know. */
+func definitionFooter(definition *Definition) []string {
+	footer := []string{}
+	if definition.Source != "" {
+		footer = append(footer, "Source: "+definition.Source)
+	}
+	if definition.Modified != nil {
+		footer = append(footer, "Last modified: "+definition.Modified.UTC().Format("2006-01-02"))
+	}
+	return footer
+}
+
+/* Write the structured fields out as text. */
+func (this *Definition) render() string {
+	lines := []string{}
+
+	header := []string{}
+	if this.Pronunciation != "" {
+		header = append(header, "/"+this.Pronunciation+"/")
+	}
+	if len(this.PartsOfSpeech) > 0 {
+		header = append(header, strings.Join(this.PartsOfSpeech, ", "))
+	}
+	if len(header) > 0 {
+		lines = append(lines, strings.Join(header, ", "), "")
+	}
+
+	if len(this.Senses) == 1 {
+		lines = append(lines, this.Senses[0])
+	} else {
+		for i, sense := range this.Senses {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, sense))
+		}
+	}
+
+	if len(this.Examples) > 0 {
+		lines = append(lines, "")
+		for _, el := range this.Examples {
+			lines = append(lines, "  \""+el+"\"")
+		}
+	}
+
+	for _, relation := range this.relations() {
+		lines = append(lines, "", relationName(relation)+": "+strings.Join(this.Related[relation], ", "))
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+/* The relations we've got related words for, in the order to write them. */
+func (this *Definition) relations() []string {
+	ret := []string{}
+	for _, el := range relationOrder {
+		if len(this.Related[el]) > 0 {
+			ret = append(ret, el)
+		}
+	}
+
+	others := []string{}
+	for el, words := range this.Related {
+		if _, ok := relationNames[el]; !ok && len(words) > 0 {
+			others = append(others, el)
+		}
+	}
+	sort.Strings(others)
+	return append(ret, others...)
+}
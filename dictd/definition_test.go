@@ -0,0 +1,45 @@
+package dictd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefinitionText(t *testing.T) {
+	def := Definition{Word: "foo", Definition: "A thing."}
+	if def.Text() != "A thing." || def.Structured() {
+		t.Errorf("Plain definition changed")
+	}
+}
+
+func TestDefinitionRender(t *testing.T) {
+	modified := time.Date(2015, 3, 14, 0, 0, 0, 0, time.UTC)
+	def := Definition{
+		Word:          "kluge",
+		Pronunciation: "klooj",
+		PartsOfSpeech: []string{"n."},
+		Senses:        []string{"A clever hack.", "Something that works."},
+		Related:       map[string][]string{"see-also": {"hack"}, "synonym": {"kludge"}},
+		Source:        "jargon.txt",
+		Modified:      &modified,
+	}
+
+	expected := strings.Join([]string{
+		"/klooj/, n.",
+		"",
+		"1. A clever hack.",
+		"2. Something that works.",
+		"",
+		"Synonyms: kludge",
+		"",
+		"See also: hack",
+		"",
+		"Source: jargon.txt",
+		"Last modified: 2015-03-14",
+	}, "\n")
+
+	if def.Text() != expected {
+		t.Errorf("Bad rendering:\n%s", def.Text())
+	}
+}
@@ -0,0 +1,253 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package dictd
+
+/* mime.go - MIME headers, and rich renderings of definitions.
+ *
+ * RFC 2229 lets a client ask (with OPTION MIME) for every text response
+ * to start with MIME headers and a blank line. The RFC only ever expects
+ * text/plain, so as an extension (advertised as "xtypes" in the banner),
+ * the client can follow OPTION MIME with the content types it would like
+ * definitions in, best first:
+ *
+ *   OPTION MIME text/html text/plain
+ *
+ * We render definitions as text/html or text/markdown ourselves, turning
+ * {cross-references} into dict:// links, unless the Database gave us its
+ * own rendering in Definition.Alternatives, in which case that wins. */
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	TextPlain    = "text/plain"
+	TextHTML     = "text/html"
+	TextMarkdown = "text/markdown"
+)
+
+/* Types we can render any Definition as. */
+var renderers = map[string]func(session *Session, definition *Definition) string{
+	TextPlain:    func(session *Session, definition *Definition) string { return definition.Text() },
+	TextHTML:     renderHTML,
+	TextMarkdown: renderMarkdown,
+}
+
+/* Work out which content type to send `definition` to `session` as. */
+func negotiate(session *Session, definition *Definition) string {
+	if !session.Options["MIME"] {
+		return TextPlain
+	}
+	for _, el := range session.Accept {
+		if _, ok := definition.Alternatives[el]; ok {
+			return el
+		}
+		if _, ok := renderers[el]; ok {
+			return el
+		}
+	}
+	return TextPlain
+}
+
+/* Get `definition` as `contentType`, which negotiate has to have picked. */
+func render(session *Session, definition *Definition, contentType string) string {
+	if alternative, ok := definition.Alternatives[contentType]; ok {
+		return alternative
+	}
+	return renderers[contentType](session, definition)
+}
+
+/* The MIME headers to start a text block of type `contentType` with, or
+ * nothing, if the client didn't ask for them. */
+func mimeHeaders(session *Session, contentType string) string {
+	if !session.Options["MIME"] {
+		return ""
+	}
+	return fmt.Sprintf(
+		"Content-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n",
+		contentType,
+	)
+}
+
+/* A {cross-reference} to another entry. */
+var crossReference = regexp.MustCompile(`\{([^{}]+)\}`)
+
+/* Get a dict:// URL (RFC 2229, section 5) defining `word` in `database`
+ * on this server. */
+func definitionURL(session *Session, database string, word string) string {
+	return "dict://" + session.DictServer.Name + "/d:" +
+		url.PathEscape(word) + ":" + url.PathEscape(database)
+}
+
+/* Split `text` up around its {cross-references}, calling `literal` with
+ * the text between them and `link` with the words inside them. */
+func linkText(text string, literal func(string) string, link func(string) string) string {
+	ret := ""
+	last := 0
+	for _, match := range crossReference.FindAllStringSubmatchIndex(text, -1) {
+		ret += literal(text[last:match[0]])
+		ret += link(text[match[2]:match[3]])
+		last = match[1]
+	}
+	return ret + literal(text[last:])
+}
+
+/* The lines of the text of `definition`, without any "\r"s. */
+func definitionLines(definition *Definition) []string {
+	body := strings.Replace(definition.Body(), "\r\n", "\n", -1)
+	return strings.Split(strings.Trim(body, "\n"), "\n")
+}
+
+/* Render `definition` as an HTML fragment. */
+func renderHTML(session *Session, definition *Definition) string {
+	link := func(word string) string {
+		return fmt.Sprintf(`<a href="%s">%s</a>`,
+			html.EscapeString(definitionURL(session, definition.DictDatabaseName, word)),
+			html.EscapeString(word),
+		)
+	}
+	text := func(text string) string {
+		return linkText(text, html.EscapeString, link)
+	}
+
+	parts := []string{}
+	if definition.Definition != "" || len(definition.Senses) == 0 {
+		/* Blank lines split paragraphs; other line breaks are kept. */
+		paragraph := []string{}
+		flush := func() {
+			if len(paragraph) > 0 {
+				parts = append(parts, "<p>"+strings.Join(paragraph, "<br>\n")+"</p>")
+				paragraph = []string{}
+			}
+		}
+		for _, line := range definitionLines(definition) {
+			if strings.TrimSpace(line) == "" {
+				flush()
+				continue
+			}
+			paragraph = append(paragraph, text(strings.TrimSpace(line)))
+		}
+		flush()
+	} else {
+		header := []string{}
+		if definition.Pronunciation != "" {
+			header = append(header, `<span class="pronunciation">/`+
+				html.EscapeString(definition.Pronunciation)+`/</span>`)
+		}
+		if len(definition.PartsOfSpeech) > 0 {
+			header = append(header, `<span class="pos">`+
+				html.EscapeString(strings.Join(definition.PartsOfSpeech, ", "))+`</span>`)
+		}
+		if len(header) > 0 {
+			parts = append(parts, "<p>"+strings.Join(header, ", ")+"</p>")
+		}
+
+		senses := []string{}
+		for _, el := range definition.Senses {
+			senses = append(senses, "<li>"+text(el)+"</li>")
+		}
+		parts = append(parts, "<ol>\n"+strings.Join(senses, "\n")+"\n</ol>")
+
+		for _, el := range definition.Examples {
+			parts = append(parts, "<blockquote>"+text(el)+"</blockquote>")
+		}
+		for _, relation := range definition.relations() {
+			links := []string{}
+			for _, el := range definition.Related[relation] {
+				links = append(links, link(el))
+			}
+			parts = append(parts, "<p>"+html.EscapeString(relationName(relation))+": "+
+				strings.Join(links, ", ")+"</p>")
+		}
+	}
+
+	for _, el := range definitionFooter(definition) {
+		parts = append(parts, `<p class="meta">`+html.EscapeString(el)+"</p>")
+	}
+	return strings.Join(parts, "\n")
+}
+
+/* Characters that mean something in Markdown. */
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`,
+)
+
+/* Render `definition` as Markdown. */
+func renderMarkdown(session *Session, definition *Definition) string {
+	link := func(word string) string {
+		return "[" + markdownEscaper.Replace(word) + "](<" +
+			definitionURL(session, definition.DictDatabaseName, word) + ">)"
+	}
+	text := func(text string) string {
+		return linkText(text, markdownEscaper.Replace, link)
+	}
+
+	lines := []string{}
+	if definition.Definition != "" || len(definition.Senses) == 0 {
+		/* Hang on to the line breaks, which Markdown would otherwise
+		 * join up, with trailing backslashes. */
+		source := definitionLines(definition)
+		for i, line := range source {
+			line = text(strings.TrimSpace(line))
+			if line != "" && i+1 < len(source) && strings.TrimSpace(source[i+1]) != "" {
+				line += `\`
+			}
+			lines = append(lines, line)
+		}
+	} else {
+		header := []string{}
+		if definition.Pronunciation != "" {
+			header = append(header, "/"+markdownEscaper.Replace(definition.Pronunciation)+"/")
+		}
+		if len(definition.PartsOfSpeech) > 0 {
+			header = append(header, "*"+markdownEscaper.Replace(
+				strings.Join(definition.PartsOfSpeech, ", "))+"*")
+		}
+		if len(header) > 0 {
+			lines = append(lines, strings.Join(header, ", "), "")
+		}
+
+		for i, el := range definition.Senses {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, text(el)))
+		}
+		for _, el := range definition.Examples {
+			lines = append(lines, "", "> "+text(el))
+		}
+		for _, relation := range definition.relations() {
+			links := []string{}
+			for _, el := range definition.Related[relation] {
+				links = append(links, link(el))
+			}
+			lines = append(lines, "", "**"+relationName(relation)+":** "+strings.Join(links, ", "))
+		}
+	}
+
+	for _, el := range definitionFooter(definition) {
+		lines = append(lines, "", "*"+markdownEscaper.Replace(el)+"*")
+	}
+	return strings.Join(lines, "\n")
+}
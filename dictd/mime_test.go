@@ -0,0 +1,69 @@
+package dictd
+
+import (
+	"testing"
+)
+
+func mimeSession(accept ...string) *Session {
+	return &Session{
+		DictServer: &Server{Name: "dict.example.org"},
+		Options:    map[string]bool{"MIME": true},
+		Accept:     accept,
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	def := Definition{Word: "foo", Definition: "A thing."}
+
+	if negotiate(&Session{Options: map[string]bool{}}, &def) != TextPlain {
+		t.Errorf("Picked a type without MIME on")
+	}
+	if negotiate(mimeSession("application/pdf", TextHTML), &def) != TextHTML {
+		t.Errorf("Didn't skip a type we can't do")
+	}
+	if negotiate(mimeSession("application/pdf"), &def) != TextPlain {
+		t.Errorf("Didn't fall back to text/plain")
+	}
+
+	def.Alternatives = map[string]string{"application/pdf": "%PDF"}
+	session := mimeSession("application/pdf")
+	if negotiate(session, &def) != "application/pdf" || render(session, &def, "application/pdf") != "%PDF" {
+		t.Errorf("Didn't use the Database's own rendering")
+	}
+}
+
+func TestMIMEHeaders(t *testing.T) {
+	if mimeHeaders(&Session{Options: map[string]bool{}}, TextPlain) != "" {
+		t.Errorf("Sent headers without MIME on")
+	}
+	expected := "Content-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n"
+	if mimeHeaders(mimeSession(), TextHTML) != expected {
+		t.Errorf("Bad headers: %q", mimeHeaders(mimeSession(), TextHTML))
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	def := Definition{
+		Word:             "foo",
+		Definition:       "Like {bar} & <baz>.\r\nSecond line.\r\n\r\nNew paragraph.",
+		DictDatabaseName: "jargon",
+	}
+	expected := `<p>Like <a href="dict://dict.example.org/d:bar:jargon">bar</a> &amp; &lt;baz&gt;.<br>` +
+		"\nSecond line.</p>\n<p>New paragraph.</p>"
+	if html := renderHTML(mimeSession(), &def); html != expected {
+		t.Errorf("Bad rendering:\n%s", html)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	def := Definition{
+		Word:             "foo",
+		Definition:       "See {two words} *now*.\r\nSecond line.",
+		DictDatabaseName: "jargon",
+	}
+	expected := `See [two words](<dict://dict.example.org/d:two%20words:jargon>) \*now\*.\` +
+		"\nSecond line."
+	if markdown := renderMarkdown(mimeSession(), &def); markdown != expected {
+		t.Errorf("Bad rendering:\n%s", markdown)
+	}
+}
@@ -0,0 +1,173 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package dictd
+
+/* querylog.go - a log of what people looked up, and if we had it.
+ *
+ * With a QueryLog on the Server, every DEFINE and MATCH that gets as far
+ * as searching is written down as a line of JSON:
+ *
+ *   {"time":"2015-03-14T15:09:26Z","session":"1426345766.0@dict.pault.ag",
+ *    "client":"dict 1.12","command":"DEFINE","database":"*",
+ *    "word":"kluge","hits":0,"latency_ms":1.2}
+ *
+ * which is easy to pick through with jq, or to total up with Misses, to
+ * find out which words people wanted that we don't have. */
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* A single line of a QueryLog. */
+type Query struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Client   string    `json:"client,omitempty"`
+	Command  string    `json:"command"`
+	Database string    `json:"database"`
+	Strategy string    `json:"strategy,omitempty"`
+	Word     string    `json:"word"`
+	Hits     int       `json:"hits"`
+	Latency  float64   `json:"latency_ms"`
+}
+
+/* QueryLog writes Queries out as JSON Lines. It's safe to use from more
+ * than one session at once. */
+type QueryLog struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+/* Create a new QueryLog, writing to `writer`. */
+func NewQueryLog(writer io.Writer) *QueryLog {
+	return &QueryLog{encoder: json.NewEncoder(writer)}
+}
+
+/* Write `query` to the log. */
+func (this *QueryLog) Write(query *Query) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.encoder.Encode(query)
+}
+
+/* Write what `session` just did with `command` to the Server's QueryLog,
+ * if it has one, and if the command got as far as searching. */
+func logQuery(session *Session, command *Command, start time.Time) {
+	queryLog := session.DictServer.QueryLog
+	if queryLog == nil || session.hits < 0 {
+		return
+	}
+
+	query := Query{
+		Time:     start.UTC(),
+		Session:  session.MsgId,
+		Client:   session.Client,
+		Command:  command.Command,
+		Database: command.Params[0],
+		Word:     command.Params[len(command.Params)-1],
+		Hits:     session.hits,
+		Latency:  float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if command.Command == "MATCH" {
+		query.Strategy = command.Params[1]
+	}
+
+	if err := queryLog.Write(&query); err != nil {
+		log.Printf("Error writing the query log: %s", err)
+	}
+}
+
+/* A word people kept looking up and not finding. */
+type Miss struct {
+	Word      string
+	Count     int
+	Databases []string
+	Last      time.Time
+}
+
+/* Read the QueryLog lines in `reader`, and total up the DEFINEs that
+ * found nothing, by word (ignoring case), most missed first. */
+func Misses(reader io.Reader) ([]*Miss, error) {
+	misses := map[string]*Miss{}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		query := Query{}
+		if err := json.Unmarshal(scanner.Bytes(), &query); err != nil {
+			return nil, fmt.Errorf("Line %d: %s", line, err)
+		}
+		if query.Command != "DEFINE" || query.Hits != 0 {
+			continue
+		}
+
+		key := strings.ToLower(query.Word)
+		miss, ok := misses[key]
+		if !ok {
+			miss = &Miss{Word: query.Word}
+			misses[key] = miss
+		}
+		miss.Count++
+		if !containsWord(miss.Databases, query.Database) {
+			miss.Databases = append(miss.Databases, query.Database)
+		}
+		if query.Time.After(miss.Last) {
+			miss.Last = query.Time
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	ret := []*Miss{}
+	for _, el := range misses {
+		ret = append(ret, el)
+	}
+	sort.Sort(byMisses(ret))
+	return ret, nil
+}
+
+/* Sort Misses most missed first, then by word. */
+type byMisses []*Miss
+
+func (s byMisses) Len() int      { return len(s) }
+func (s byMisses) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byMisses) Less(i, j int) bool {
+	if s[i].Count != s[j].Count {
+		return s[i].Count > s[j].Count
+	}
+	return strings.ToLower(s[i].Word) < strings.ToLower(s[j].Word)
+}
@@ -0,0 +1,63 @@
+package dictd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLogQuery(t *testing.T) {
+	buffer := bytes.Buffer{}
+	server := NewServer("test")
+	server.QueryLog = NewQueryLog(&buffer)
+	session := Session{MsgId: "1.0@test", Client: "dict 1.12", DictServer: &server}
+
+	command := Command{Command: "MATCH", Params: []string{"jargon", "lev", "kluge"}}
+	session.hits = 3
+	logQuery(&session, &command, time.Now())
+
+	/* Commands that never searched aren't logged. */
+	session.hits = -1
+	logQuery(&session, &command, time.Now())
+
+	query := Query{}
+	if err := json.Unmarshal(buffer.Bytes(), &query); err != nil {
+		t.Fatal(err)
+	}
+	if query.Command != "MATCH" || query.Database != "jargon" || query.Strategy != "lev" ||
+		query.Word != "kluge" || query.Hits != 3 || query.Client != "dict 1.12" {
+		t.Errorf("Bad query: %v", query)
+	}
+}
+
+func TestMisses(t *testing.T) {
+	buffer := bytes.Buffer{}
+	queryLog := NewQueryLog(&buffer)
+	last := time.Date(2015, 3, 14, 0, 0, 0, 0, time.UTC)
+	for _, el := range []Query{
+		{Command: "DEFINE", Database: "*", Word: "kluge", Hits: 0},
+		{Command: "DEFINE", Database: "jargon", Word: "Kluge", Hits: 0, Time: last},
+		{Command: "DEFINE", Database: "*", Word: "hack", Hits: 1},
+		{Command: "MATCH", Database: "*", Word: "frob", Hits: 0},
+		{Command: "DEFINE", Database: "*", Word: "cruft", Hits: 0},
+	} {
+		query := el
+		queryLog.Write(&query)
+	}
+
+	misses, err := Misses(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(misses) != 2 {
+		t.Fatalf("Got %d misses, not 2", len(misses))
+	}
+	if misses[0].Word != "kluge" || misses[0].Count != 2 || len(misses[0].Databases) != 2 ||
+		!misses[0].Last.Equal(last) {
+		t.Errorf("Bad miss: %v", misses[0])
+	}
+	if misses[1].Word != "cruft" || misses[1].Count != 1 {
+		t.Errorf("Bad miss: %v", misses[1])
+	}
+}
@@ -28,6 +28,7 @@ package dictd
 
 import (
 	"errors"
+	"time"
 )
 
 /* Command is the encapsulation for a user's request of the Server. */
@@ -36,12 +37,36 @@ type Command struct {
 	Params  []string
 }
 
-/* Definition is the encapsulation of a response for a given Entry. */
+/* Definition is the encapsulation of a response for a given Entry.
+ *
+ * Word is the headword as the dictionary writes it ("NASA", not the
+ * "nasa" we look it up as). Word and Definition are all a classic client
+ * will see. Everything after them is optional structure a Database can
+ * fill in if it has it; see definition.go for how it's turned into text. */
 type Definition struct {
-	Word             string
-	Definition       string
-	DictDatabase     Database
-	DictDatabaseName string
+	Word             string   `json:"word"`
+	Definition       string   `json:"definition,omitempty"`
+	DictDatabase     Database `json:"-"`
+	DictDatabaseName string   `json:"database,omitempty"`
+
+	Pronunciation string   `json:"pronunciation,omitempty"`
+	PartsOfSpeech []string `json:"pos,omitempty"`
+	Senses        []string `json:"senses,omitempty"`
+	Examples      []string `json:"examples,omitempty"`
+
+	/* Where the definition came from (a file, a URL, a book). */
+	Source string `json:"source,omitempty"`
+
+	/* Other words, by how they're related ("synonym", "hypernym",
+	 * "see-also", "translation"). */
+	Related map[string][]string `json:"related,omitempty"`
+
+	/* When the definition was last changed, if we know. */
+	Modified *time.Time `json:"modified,omitempty"`
+
+	/* The Database's own renderings of the definition, by content type
+	 * ("text/html"), for clients that ask for them; see mime.go. */
+	Alternatives map[string]string `json:"alternatives,omitempty"`
 }
 
 /* Database is an interface for external Database "Backends" to implement. */
@@ -68,8 +93,23 @@ type Database interface {
  * This contains a bundle of useful helpers, as well as a few data structures
  * to handle registered Databases and Commands. */
 type Server struct {
-	Name          string
-	Info          string
+	Name string
+	Info string
+
+	/* Columns to wrap text/plain lines to; 0 or less to send them as
+	 * they are. */
+	Width int
+
+	/* Strategies to MATCH with, in order, to suggest words when DEFINE
+	 * finds nothing, and how many suggestions to give; see suggest.go.
+	 * No strategies, no suggestions. */
+	SuggestStrategies []string
+	SuggestLimit      int
+
+	/* Where to write down DEFINEs and MATCHes, if anywhere; see
+	 * querylog.go. */
+	QueryLog *QueryLog
+
 	databases     map[string]Database
 	strats        map[string]string
 	databaseOrder []string
@@ -216,6 +256,8 @@ func NewServer(name string) Server {
 	server := Server{
 		Name:          name,
 		Info:          "",
+		Width:         DefaultWidth,
+		SuggestLimit:  DefaultSuggestLimit,
 		commands:      map[string]func(*Session, Command){},
 		databases:     map[string]Database{},
 		databaseOrder: []string{},
@@ -224,6 +266,7 @@ func NewServer(name string) Server {
 		strats: map[string]string{
 			"prefix": "Match based on the word's prefix",
 			"match":  "Do an exact match",
+			"exact":  "Match headwords exactly",
 		},
 	}
 	registerDefaultHandlers(&server)
@@ -44,6 +44,14 @@ type Session struct {
 	Connection *textproto.Conn
 	DictServer *Server
 	Options    map[string]bool
+
+	/* Content types the client would like definitions in, best first,
+	 * from OPTION MIME. */
+	Accept []string
+
+	/* How many results the command being handled found, for the
+	 * QueryLog, or -1 if it didn't get as far as searching. */
+	hits int
 }
 
 func consumeAtom(buf string) (token string, buffer string, err error) {
@@ -121,26 +129,41 @@ func parseLine(line string) (*Command, error) {
 /* Given a dict.Session and a dict.Command, route the command to the proper
  * handler, and dispatch the command. */
 func handleCommand(session *Session, command *Command) {
-	log.Printf("Incomming command from %s: %s", session.MsgId, command.Command)
+	/* Just the command; what people look up only goes in the QueryLog,
+	 * if the Server has been given one. */
+	log.Printf("Incoming command from %s: %s", session.MsgId, command.Command)
+
+	start := time.Now()
+	session.hits = -1
 	handler := session.DictServer.GetHandler(command)
 	if handler == nil {
 		unknownCommandHandler(session, *command)
 	} else {
 		handler(session, *command)
 	}
+	logQuery(session, command, start)
 }
 
 /* Helper for commands to write out a text block */
 func WriteTextBlock(session *Session, stream string) {
-	if session.Options["MIME"] {
-		session.Connection.Writer.PrintfLine(
-			"Content-type: text/plain; charset=utf-8\n" +
-				"Content-transfer-encoding: 8bit\n",
-		)
+	WriteTypedTextBlock(session, TextPlain, stream)
+}
 
-	}
+/* Helper for commands to write out a text block meant to be read by
+ * people (rather than parsed, like the SHOW DB list), wrapped to the
+ * Server's Width; see text.go. */
+func WriteWrappedTextBlock(session *Session, stream string) {
+	WriteTextBlock(session, reflow(stream, session.DictServer.Width))
+}
+
+/* Helper for commands to write out a text block of the content type
+ * `contentType`, with MIME headers in front of it if the client asked.
+ * No line goes out over the RFC's limit; see text.go. */
+func WriteTypedTextBlock(session *Session, contentType string, stream string) {
+	stream = limitLines(stream, maxLineLength)
 
 	writer := session.Connection.Writer.DotWriter()
+	writer.Write([]byte(mimeHeaders(session, contentType)))
 	writer.Write([]byte(stream))
 	writer.Close()
 }
@@ -0,0 +1,120 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package dictd
+
+/* suggest.go - suggestions for words we couldn't DEFINE.
+ *
+ * A DEFINE that finds nothing leaves the client to go and MATCH for
+ * something close by itself. If the Server has a SuggestStrategies chain,
+ * it'll do that for them: the strategies are tried in order, and the
+ * matches from the first one to find anything are the suggestions.
+ *
+ * Every client gets the suggestions in the text of the 552, which RFC
+ * 2229 leaves up to us:
+ *
+ *   552 no match; perhaps you meant: kludge, kluged
+ *
+ * and, as an extension (advertised as "xsuggest" in the banner), clients
+ * that send OPTION SUGGEST get them as a 152 match list first, just like
+ * the one MATCH sends, before the 552. */
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* The strategies to suggest words with, if the Server doesn't get told
+ * otherwise. Databases that don't know a strategy just won't find
+ * anything with it. */
+var DefaultSuggestStrategies = []string{"exact", "prefix", "levenshtein", "metaphone"}
+
+/* How many suggestions to give, unless the Server is told otherwise. */
+const DefaultSuggestLimit = 10
+
+/* Find words like `query` in `database`, to suggest when DEFINE comes up
+ * empty. */
+func (this *Server) Suggest(database string, query string) ([]*Definition, error) {
+	for _, strat := range this.SuggestStrategies {
+		defs, err := this.Match(database, query, strat)
+		if err != nil {
+			return nil, err
+		}
+
+		/* The same word can come from more than one Database, or from
+		 * more than one index. */
+		ret := []*Definition{}
+		seen := map[string]bool{}
+		for _, el := range defs {
+			key := el.DictDatabaseName + "\n" + strings.ToLower(el.Word)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ret = append(ret, el)
+		}
+
+		if len(ret) > 0 {
+			if this.SuggestLimit > 0 && len(ret) > this.SuggestLimit {
+				ret = ret[:this.SuggestLimit]
+			}
+			return ret, nil
+		}
+	}
+	return []*Definition{}, nil
+}
+
+/* Tell `session` we couldn't find `word` in `database`, with whatever we
+ * can suggest instead. */
+func writeNoDefinition(session *Session, database string, word string) {
+	suggestions, err := session.DictServer.Suggest(database, word)
+	if err != nil || len(suggestions) == 0 {
+		WriteCode(session, 552, "no match")
+		return
+	}
+
+	if session.Options["SUGGEST"] {
+		writeMatches(session, suggestions)
+	}
+
+	words := []string{}
+	for _, el := range suggestions {
+		if !containsWord(words, el.Word) {
+			words = append(words, el.Word)
+		}
+	}
+	message := "no match; perhaps you meant: " + strings.Join(words, ", ")
+	if len(message) > maxLineLength-4 {
+		/* Room for the "552 ". */
+		message = fmt.Sprintf("no match; %d suggestions", len(suggestions))
+	}
+	WriteCode(session, 552, message)
+}
+
+/* Check to see if `words` has `word` in it already. */
+func containsWord(words []string, word string) bool {
+	for _, el := range words {
+		if el == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package dictd
+
+import (
+	"testing"
+)
+
+/* A Database that only knows how to MATCH, with canned answers. */
+type suggestDatabase struct {
+	matches map[string][]string
+}
+
+func (this *suggestDatabase) Match(name string, query string, strat string) []*Definition {
+	ret := []*Definition{}
+	for _, el := range this.matches[strat] {
+		ret = append(ret, &Definition{Word: el, DictDatabase: this, DictDatabaseName: name})
+	}
+	return ret
+}
+
+func (this *suggestDatabase) Define(name string, query string) []*Definition {
+	return []*Definition{}
+}
+
+func (this *suggestDatabase) Info(name string) string                  { return "" }
+func (this *suggestDatabase) Description(name string) string           { return "" }
+func (this *suggestDatabase) Strategies(name string) map[string]string { return map[string]string{} }
+
+func TestSuggest(t *testing.T) {
+	server := NewServer("test")
+	server.SuggestStrategies = DefaultSuggestStrategies
+	server.SuggestLimit = 2
+	server.RegisterDatabase(&suggestDatabase{matches: map[string][]string{
+		"levenshtein": {"kludge", "Kludge", "kluged", "klutz"},
+		"metaphone":   {"clutch"},
+	}}, "jargon", true)
+
+	defs, err := server.Suggest("*", "kluge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || defs[0].Word != "kludge" || defs[1].Word != "kluged" {
+		t.Errorf("Bad suggestions: %v", defs)
+	}
+
+	if _, err := server.Suggest("nope", "kluge"); err == nil {
+		t.Errorf("No error for a missing database")
+	}
+
+	server.SuggestStrategies = []string{}
+	if defs, _ := server.Suggest("*", "kluge"); len(defs) != 0 {
+		t.Errorf("Suggested without any strategies")
+	}
+}
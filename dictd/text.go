@@ -0,0 +1,144 @@
+/**
+ * Copyright (c) Paul R. Tagliamonte, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a
+ * copy of this software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation
+ * the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the
+ * Software is furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+ * THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+ * DEALINGS IN THE SOFTWARE. */
+
+package dictd
+
+/* text.go - laying out text blocks for the wire.
+ *
+ * RFC 2229 caps text lines at 1024 octets (CRLF and all), and would like
+ * them kept to around 72 columns. Plenty of what comes out of the
+ * Databases (the Urban Dictionary, long Jargon File paragraphs) doesn't
+ * care, so text/plain definitions (and SHOW INFO and SHOW SERVER) are
+ * wrapped to the Server's Width, and every block has anything still over
+ * the limit broken up. Lists clients have to parse, like the 152 MATCH
+ * list, are never wrapped. */
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+/* The longest line we'll send: 1024 octets, less the CRLF, and less one
+ * more in case the line starts with a "." that has to be doubled. */
+const maxLineLength = 1024 - 2 - 1
+
+/* The width lines are wrapped to, unless the Server is told otherwise. */
+const DefaultWidth = 72
+
+var (
+	/* Runs of spaces inside a line mean someone lined things up by hand
+	 * (tables, ASCII art), and wrapping it would ruin that. */
+	alignedText = regexp.MustCompile(`\S(  +|\t)\S`)
+
+	/* "1. ", "* " and the like, which wrapped lines hang under. */
+	listMarker = regexp.MustCompile(`^(\d+[.)]|[*+-])\s+`)
+
+	codeFence = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+/* Wrap the lines of `text` that are longer than `width` columns, keeping
+ * their indentation. Line breaks that are already there are left alone,
+ * as are preformatted lines: anything indented with a tab, lined up with
+ * runs of spaces, or inside a ``` block. A `width` of 0 or less leaves
+ * `text` alone. */
+func reflow(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+
+	lines := []string{}
+	fence := false
+	for _, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		if codeFence.MatchString(line) {
+			fence = !fence
+			lines = append(lines, line)
+			continue
+		}
+		if fence || utf8.RuneCountInString(line) <= width || preformatted(line) {
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, wrapLine(line, width)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+/* Check to see if `line` was laid out by hand. */
+func preformatted(line string) bool {
+	return strings.HasPrefix(line, "\t") || alignedText.MatchString(strings.TrimSpace(line))
+}
+
+/* Break `line` up at spaces into lines no wider than `width`, where we can.
+ * Lines after the first are indented as far as the first, or, if it starts
+ * a list item, as far as its text. */
+func wrapLine(line string, width int) []string {
+	text := strings.TrimLeft(line, " ")
+	indent := line[:len(line)-len(text)]
+	hanging := indent
+	if marker := listMarker.FindString(text); marker != "" {
+		hanging += strings.Repeat(" ", utf8.RuneCountInString(marker))
+	}
+
+	lines := []string{}
+	current := indent
+	empty := true
+	for _, word := range strings.Fields(text) {
+		if !empty && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, current)
+			current = hanging
+			empty = true
+		}
+		if !empty {
+			current += " "
+		}
+		current += word
+		empty = false
+	}
+	return append(lines, current)
+}
+
+/* Break up any line of `text` over `limit` octets, at the last space
+ * before the limit if there is one, or wherever it has to otherwise
+ * (without splitting a UTF-8 character). */
+func limitLines(text string, limit int) string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		for len(line) > limit {
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				/* Not UTF-8 at all; just cut it. */
+				cut = limit
+			}
+			if space := strings.LastIndex(line[:cut], " "); space > 0 {
+				cut = space
+			}
+			lines = append(lines, line[:cut])
+			line = strings.TrimLeft(line[cut:], " ")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
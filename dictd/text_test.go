@@ -0,0 +1,83 @@
+package dictd
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestReflow(t *testing.T) {
+	text := "  The quick brown fox jumps over the lazy dog.\r\nShort line."
+	expected := "  The quick brown\n  fox jumps over\n  the lazy dog.\nShort line."
+	if wrapped := reflow(text, 18); wrapped != expected {
+		t.Errorf("Bad wrapping:\n%s", wrapped)
+	}
+}
+
+func TestReflowListItem(t *testing.T) {
+	expected := "1. The quick brown\n   fox jumps."
+	if wrapped := reflow("1. The quick brown fox jumps.", 18); wrapped != expected {
+		t.Errorf("Bad wrapping:\n%s", wrapped)
+	}
+}
+
+func TestReflowPreformatted(t *testing.T) {
+	for _, text := range []string{
+		"name      value      another column here",
+		"\tindented with a tab, which is code, probably",
+		"```\nthe quick brown fox jumps over the lazy dog\n```",
+	} {
+		if wrapped := reflow(text, 18); wrapped != text {
+			t.Errorf("Wrapped preformatted text:\n%s", wrapped)
+		}
+	}
+}
+
+func TestReflowDisabled(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog."
+	if reflow(text, 0) != text {
+		t.Errorf("Wrapped with a width of 0")
+	}
+}
+
+func TestLimitLines(t *testing.T) {
+	text := strings.Repeat("word ", 500) + "\n" + strings.Repeat("é", 1000)
+	for _, line := range strings.Split(limitLines(text, maxLineLength), "\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Line is %d octets", len(line))
+		}
+		if !strings.HasPrefix(line, "word") && strings.Trim(line, "é") != "" {
+			t.Errorf("Split a character: %q", line[:10])
+		}
+	}
+}
+
+/* A connection that writes into a buffer, and never has anything to read. */
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (this *bufferConn) Close() error { return nil }
+
+func TestMatchesAreNotWrapped(t *testing.T) {
+	server := NewServer("test")
+	server.Width = 18
+	conn := bufferConn{}
+	session := Session{
+		Connection: textproto.NewConn(&conn),
+		DictServer: &server,
+		Options:    map[string]bool{},
+	}
+
+	writeMatches(&session, []*Definition{
+		{Word: "Hacker Ethic, the long multiword headword", DictDatabaseName: "jargon"},
+	})
+	session.Connection.Writer.W.Flush()
+
+	expected := "152 1 matches found\r\n" +
+		"jargon \"Hacker Ethic, the long multiword headword\"\r\n.\r\n"
+	if conn.String() != expected {
+		t.Errorf("Bad match list:\n%s", conn.String())
+	}
+}
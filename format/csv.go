@@ -0,0 +1,294 @@
+package format
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+func init() {
+	Register(Format{
+		Name:       "csv",
+		Extensions: []string{".csv"},
+		New: func(options Options) (Parser, error) {
+			return NewCSVParser(options, ',')
+		},
+	})
+	Register(Format{
+		Name:       "tsv",
+		Extensions: []string{".tsv", ".tab"},
+		New: func(options Options) (Parser, error) {
+			return NewCSVParser(options, '\t')
+		},
+	})
+}
+
+/* CSVParser reads glossaries out of spreadsheets, one word per row. It
+ * understands these options:
+ *
+ *   separator     - the column separator, one character, or "tab".
+ *   header        - "true" (the default) if the first row names the
+ *                   columns, "false" if it's a word like any other.
+ *   quote         - "true" (the default) to handle "quoted, fields",
+ *                   "false" to take quotes as they come (which is how most
+ *                   TSV files are written).
+ *   comment       - rows starting with this character are skipped.
+ *   encoding      - the text encoding, like "windows-1252" (default UTF-8).
+ *   word          - the column with the headword in it (default "word", or
+ *                   the first column, if there's no header).
+ *   definition    - the column with the definition (default "definition",
+ *                   or the second column).
+ *   pronunciation - the column with the pronunciation, if any.
+ *   pos           - the column with the part of speech, if any.
+ *   extra         - a comma separated list of columns to add to the end
+ *                   of the definition, as "Column: value".
+ *
+ * Columns are either the name in the header (in any case), or a number,
+ * counting from 1. */
+type CSVParser struct {
+	separator rune
+	header    bool
+	quote     bool
+	comment   rune
+	encoding  encoding.Encoding
+
+	word          string
+	definition    string
+	pronunciation string
+	pos           string
+	extra         []string
+}
+
+func NewCSVParser(options Options, separator rune) (*CSVParser, error) {
+	parser := CSVParser{
+		separator: separator,
+		header:    true,
+		quote:     true,
+	}
+
+	var err error
+	for key, value := range options {
+		switch key {
+		case "separator":
+			parser.separator, err = csvRune(key, value)
+		case "comment":
+			parser.comment, err = csvRune(key, value)
+		case "header":
+			parser.header, err = strconv.ParseBool(value)
+		case "quote":
+			parser.quote, err = strconv.ParseBool(value)
+		case "encoding":
+			parser.encoding, err = lookupEncoding(value)
+		case "word":
+			parser.word = value
+		case "definition":
+			parser.definition = value
+		case "pronunciation":
+			parser.pronunciation = value
+		case "pos":
+			parser.pos = value
+		case "extra":
+			parser.extra = strings.Split(value, ",")
+		default:
+			err = fmt.Errorf("The csv and tsv formats don't take a %q option", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if parser.encoding == nil {
+		parser.encoding, _ = lookupEncoding("")
+	}
+	if parser.word == "" {
+		parser.word = "1"
+		if parser.header {
+			parser.word = "word"
+		}
+	}
+	if parser.definition == "" {
+		parser.definition = "2"
+		if parser.header {
+			parser.definition = "definition"
+		}
+	}
+	return &parser, nil
+}
+
+/* Get the single character option `key`, which is `value`. */
+func csvRune(key string, value string) (rune, error) {
+	if value == "tab" || value == "\\t" {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("The %s option has to be one character, not %q", key, value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
+
+func (this *CSVParser) Parse(reader io.Reader) Iterator {
+	reader = decodeReader(reader, this.encoding)
+
+	iterator := csvIterator{parser: this}
+	if this.quote {
+		iterator.csv = csv.NewReader(reader)
+		iterator.csv.Comma = this.separator
+		iterator.csv.Comment = this.comment
+		iterator.csv.FieldsPerRecord = -1
+	} else {
+		iterator.lines = newLineScanner(reader)
+	}
+	return &iterator
+}
+
+type csvIterator struct {
+	parser *CSVParser
+
+	/* One or the other, depending on if we're handling quotes. */
+	csv   *csv.Reader
+	lines *bufio.Scanner
+	line  int
+
+	/* Column names, as they are in the header, and where they are. */
+	names   []string
+	columns map[string]int
+}
+
+/* Read the next row, and the line it started on. */
+func (this *csvIterator) row() ([]string, int, error) {
+	if this.csv != nil {
+		row, err := this.csv.Read()
+		if err != nil {
+			if parseError, ok := err.(*csv.ParseError); ok {
+				return nil, 0, &ParseError{Line: parseError.Line, Message: parseError.Err.Error()}
+			}
+			return nil, 0, err
+		}
+		line, _ := this.csv.FieldPos(0)
+		return row, line, nil
+	}
+
+	for this.lines.Scan() {
+		this.line++
+		text := strings.TrimRight(this.lines.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if this.parser.comment != 0 && strings.HasPrefix(text, string(this.parser.comment)) {
+			continue
+		}
+		return strings.Split(text, string(this.parser.separator)), this.line, nil
+	}
+	if err := this.lines.Err(); err != nil {
+		return nil, 0, &ParseError{Line: this.line + 1, Message: err.Error()}
+	}
+	return nil, 0, io.EOF
+}
+
+/* Work out which column `name` refers to. */
+func (this *csvIterator) column(name string, line int) (int, error) {
+	if index, ok := this.columns[strings.ToLower(name)]; ok {
+		return index, nil
+	}
+	if index, err := strconv.Atoi(name); err == nil && index > 0 {
+		return index - 1, nil
+	}
+	return 0, &ParseError{Line: line, Message: fmt.Sprintf("No column called %q", name)}
+}
+
+/* Get the value of the column `name` in `row`, or "" if it's empty (or
+ * we weren't asked for it). */
+func (this *csvIterator) field(row []string, name string, line int) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	index, err := this.column(name, line)
+	if err != nil {
+		return "", err
+	}
+	if index >= len(row) {
+		return "", nil
+	}
+	return strings.TrimSpace(row[index]), nil
+}
+
+func (this *csvIterator) Next() (*Entry, error) {
+	if this.columns == nil {
+		this.columns = map[string]int{}
+		if this.parser.header {
+			row, _, err := this.row()
+			if err != nil {
+				return nil, err
+			}
+			for i, el := range row {
+				name := strings.TrimSpace(el)
+				this.names = append(this.names, name)
+				this.columns[strings.ToLower(name)] = i
+			}
+		}
+	}
+
+	for {
+		row, line, err := this.row()
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		entry := Entry{}
+		if entry.Word, err = this.field(row, this.parser.word, line); err != nil {
+			return nil, err
+		}
+		if entry.Word == "" {
+			return nil, &ParseError{Line: line, Message: "Row with no headword"}
+		}
+		if entry.Definition, err = this.field(row, this.parser.definition, line); err != nil {
+			return nil, err
+		}
+		if entry.Pronunciation, err = this.field(row, this.parser.pronunciation, line); err != nil {
+			return nil, err
+		}
+
+		pos, err := this.field(row, this.parser.pos, line)
+		if err != nil {
+			return nil, err
+		}
+		if pos != "" {
+			entry.PartsOfSpeech = []string{pos}
+		}
+
+		lines := []string{entry.Definition}
+		for _, name := range this.parser.extra {
+			value, err := this.field(row, name, line)
+			if err != nil {
+				return nil, err
+			}
+			if value != "" {
+				lines = append(lines, this.label(name)+": "+value)
+			}
+		}
+		entry.Definition = strings.TrimSpace(strings.Join(lines, "\r\n"))
+		if entry.Definition != "" {
+			entry.Senses = []string{entry.Definition}
+		}
+		return &entry, nil
+	}
+}
+
+/* What to call the column `name` when it's added to a definition: its
+ * name in the header, if it has one. */
+func (this *csvIterator) label(name string) string {
+	if index, err := this.column(name, 0); err == nil && index < len(this.names) {
+		return this.names[index]
+	}
+	return name
+}
@@ -0,0 +1,64 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSVParser(t *testing.T) {
+	entries := parseString(t, "csv", Options{"extra": "Owner"},
+		"Word,Definition,Owner\nSLA,\"Service level agreement, the promise\",ops\n\n,,\nRFC,Request for comments,\n")
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+
+	if entries[0].Word != "SLA" || entries[0].Definition != "Service level agreement, the promise\r\nOwner: ops" {
+		t.Errorf("Bad first entry %q", entries[0].Definition)
+	}
+
+	if entries[1].Definition != "Request for comments" {
+		t.Errorf("Bad empty extra column handling")
+	}
+}
+
+func TestTSVParser(t *testing.T) {
+	entries := parseString(t, "tsv", Options{"header": "false", "quote": "false", "pos": "3"},
+		"SLA\t\"Service\" level agreement\tn.\n")
+
+	if entries[0].Definition != "\"Service\" level agreement" {
+		t.Errorf("Bad unquoted field %q", entries[0].Definition)
+	}
+
+	if strings.Join(entries[0].PartsOfSpeech, ",") != "n." {
+		t.Errorf("Bad part of speech column")
+	}
+}
+
+func TestCSVEncoding(t *testing.T) {
+	entries := parseString(t, "csv", Options{"encoding": "latin1", "header": "false"},
+		"caf\xe9,coffee\n")
+
+	if entries[0].Word != "café" {
+		t.Errorf("Bad encoding conversion %q", entries[0].Word)
+	}
+
+	entries = parseString(t, "csv", Options{"header": "false"}, "\xef\xbb\xbfword,thing\n")
+	if entries[0].Word != "word" {
+		t.Errorf("Didn't strip the byte order mark")
+	}
+}
+
+func TestCSVErrors(t *testing.T) {
+	format, _ := Get("csv")
+	parser, _ := format.New(Options{"word": "Term"})
+	_, err := ReadAll(parser.Parse(strings.NewReader("Word,Definition\nSLA,thing\n")))
+
+	if parseError, ok := err.(*ParseError); !ok || parseError.Line != 2 {
+		t.Errorf("Didn't get a parse error for line 2: %v", err)
+	}
+
+	if _, err := format.New(Options{"encoding": "klingon"}); err == nil {
+		t.Errorf("Took an encoding that doesn't exist")
+	}
+}
@@ -0,0 +1,128 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"pault.ag/go/dictd/dictd"
+)
+
+/* The digits dictd uses to write offsets and lengths in .index files.
+ * This isn't quite base64: numbers are written most significant digit
+ * first, with no padding, so 0 is "A" and 64 is "BA". */
+const dictdDigits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+/* Encode the number `number` the way dictd does in .index files. */
+func dictdNumber(number int64) string {
+	if number == 0 {
+		return "A"
+	}
+	digits := ""
+	for number > 0 {
+		digits = string(dictdDigits[number%64]) + digits
+		number /= 64
+	}
+	return digits
+}
+
+/* An entry in the .index file we haven't written yet. */
+type dictdIndexEntry struct {
+	word   string
+	offset int64
+	length int64
+}
+
+/* DictdWriter writes Definitions out as a classic dictd database, the
+ * (uncompressed) .dict file, with the text of each entry, and the .index
+ * file, with where to find them. The index has to be sorted, so it's
+ * only written on Close.
+ *
+ * Left to itself, dictd reads indexes as Latin-1, and compares headwords
+ * ignoring everything but letters, numbers and spaces, which isn't the
+ * order we sort them in. So, like `dictfmt --utf8 --allchars`, we add the
+ * 00-database-utf8 and 00-database-allchars entries, which tell it the
+ * index is UTF-8, and that every character counts. */
+type DictdWriter struct {
+	index   io.Writer
+	dict    *bufio.Writer
+	offset  int64
+	entries []dictdIndexEntry
+}
+
+func NewDictdWriter(index io.Writer, dict io.Writer) *DictdWriter {
+	return &DictdWriter{
+		index: index,
+		dict:  bufio.NewWriter(dict),
+	}
+}
+
+func (this *DictdWriter) Write(definition *dictd.Definition) error {
+	if strings.ContainsAny(definition.Word, "\t\r\n") {
+		return fmt.Errorf("Can't write %q in the dictd format", definition.Word)
+	}
+
+	/* dictfmt puts the headword on the first line of the entry, and
+	 * clients expect it there. */
+	text := strings.Replace(definition.Body(), "\r\n", "\n", -1)
+	text = definition.Word + "\n" + strings.TrimRight(text, "\n") + "\n"
+
+	length, err := this.dict.WriteString(text)
+	if err != nil {
+		return err
+	}
+
+	this.entries = append(this.entries, dictdIndexEntry{
+		word:   definition.Word,
+		offset: this.offset,
+		length: int64(length),
+	})
+	this.offset += int64(length)
+	return nil
+}
+
+/* The entries that tell dictd how to read the index. */
+var dictdHeaders = []string{"00-database-allchars", "00-database-utf8"}
+
+func (this *DictdWriter) Close() error {
+	for _, el := range dictdHeaders {
+		if err := this.Write(&dictd.Definition{Word: el}); err != nil {
+			return err
+		}
+	}
+	if err := this.dict.Flush(); err != nil {
+		return err
+	}
+
+	sort.Sort(byDictdWord(this.entries))
+
+	index := bufio.NewWriter(this.index)
+	for _, el := range this.entries {
+		_, err := fmt.Fprintf(index, "%s\t%s\t%s\n",
+			el.word,
+			dictdNumber(el.offset),
+			dictdNumber(el.length),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return index.Flush()
+}
+
+/* With 00-database-allchars and 00-database-utf8, dictd wants the index
+ * in case insensitive order, with every character counting. */
+type byDictdWord []dictdIndexEntry
+
+func (s byDictdWord) Len() int      { return len(s) }
+func (s byDictdWord) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byDictdWord) Less(i, j int) bool {
+	a := strings.ToLower(s[i].word)
+	b := strings.ToLower(s[j].word)
+	if a != b {
+		return a < b
+	}
+	return s[i].word < s[j].word
+}
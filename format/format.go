@@ -0,0 +1,291 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+
+	"pault.ag/go/dictd/dictd"
+)
+
+/* A single entry read out of a dictionary file. Word and Definition are
+ * always set; the rest are whatever structure the format has, if any. */
+type Entry struct {
+	Word       string
+	Definition string
+
+	/* How to say it, in whatever notation the dictionary uses. */
+	Pronunciation string
+
+	/* Parts of speech ("n.", "vt."), as the dictionary abbreviates them. */
+	PartsOfSpeech []string
+
+	/* The definition split up into its numbered senses, or the whole
+	 * thing as one sense if it isn't numbered. */
+	Senses []string
+
+	/* Other headwords this entry refers to. */
+	References []string
+
+	/* Sentences using the word. */
+	Examples []string
+
+	/* Words meaning the same thing, in at least one sense. */
+	Synonyms []string
+
+	/* More general words ("canine" for "dog"). */
+	Hypernyms []string
+
+	/* The word in the other language, for bilingual dictionaries. */
+	Translations []string
+
+	/* Where the entry came from, if the file says. */
+	Source string
+
+	/* When the entry was last changed, if the file says. */
+	Modified *time.Time
+}
+
+/* Turn the Entry into a dictd.Definition, for writing out or serving. */
+func (this *Entry) AsDefinition() *dictd.Definition {
+	definition := dictd.Definition{
+		Word:          this.Word,
+		Definition:    this.Definition,
+		Pronunciation: this.Pronunciation,
+		PartsOfSpeech: this.PartsOfSpeech,
+		Senses:        this.Senses,
+		Examples:      this.Examples,
+		Source:        this.Source,
+		Modified:      this.Modified,
+	}
+
+	related := map[string][]string{
+		"synonym":     this.Synonyms,
+		"hypernym":    this.Hypernyms,
+		"translation": this.Translations,
+		"see-also":    this.References,
+	}
+	for relation, words := range related {
+		if len(words) > 0 {
+			if definition.Related == nil {
+				definition.Related = map[string][]string{}
+			}
+			definition.Related[relation] = words
+		}
+	}
+
+	/* A sense that's just the whole definition again isn't structure. */
+	if len(definition.Senses) == 1 && definition.Senses[0] == definition.Definition {
+		definition.Senses = nil
+	}
+	return &definition
+}
+
+/* Iterator hands back Entries one at a time. Once there are none left,
+ * Next returns io.EOF, and any other error means the file's broken. */
+type Iterator interface {
+	Next() (*Entry, error)
+}
+
+/* Parser is the interface every dictionary format implements. Parse
+ * starts reading entries out of `reader`; nothing's read until Next is
+ * called on what it returns. */
+type Parser interface {
+	Parse(reader io.Reader) Iterator
+}
+
+/* Format specific settings, like the column separator for CSV files. Each
+ * format says which (if any) it understands. */
+type Options map[string]string
+
+/* Format is a dictionary format we know how to read. */
+type Format struct {
+	/* What the format is called, as given to -format. */
+	Name string
+
+	/* File extensions (with the dot) files in this format tend to have. */
+	Extensions []string
+
+	/* Check the first line of a file with an extension we don't know,
+	 * to see if it's in this format. May be nil. */
+	Detect func(line string) bool
+
+	/* Create a Parser for this format. */
+	New func(options Options) (Parser, error)
+}
+
+var formats = map[string]Format{}
+
+/* Register the Format `format`, so it can be found by Get and ForPath.
+ * Formats register themselves when the package is loaded. */
+func Register(format Format) {
+	formats[format.Name] = format
+}
+
+/* Get the Format called `name`. */
+func Get(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("Unknown format %q", name)
+	}
+	return format, nil
+}
+
+/* Get the names of every registered Format. */
+func Names() []string {
+	names := []string{}
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/* Find the Format files with the same extension as `path` tend to be in,
+ * if there is one. */
+func ForExtension(path string) (Format, bool) {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, name := range Names() {
+		for _, el := range formats[name].Extensions {
+			if el == extension {
+				return formats[name], true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+/* Work out which Format the file at `path` is in, going by its extension,
+ * or failing that, how it starts. */
+func ForPath(path string) (Format, error) {
+	if format, ok := ForExtension(path); ok {
+		return format, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Format{}, err
+	}
+	defer file.Close()
+
+	/* The first line that isn't blank ought to give it away. */
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		for _, name := range Names() {
+			if detect := formats[name].Detect; detect != nil && detect(line) {
+				return formats[name], nil
+			}
+		}
+		break
+	}
+	if err := scanner.Err(); err != nil {
+		return Format{}, err
+	}
+	return Format{}, fmt.Errorf("Can't tell what format %s is in", path)
+}
+
+/* Open the dictionary file at `path` in the format `name` (or whatever
+ * ForPath thinks it is, if that's empty). Close the returned Closer once
+ * you're done with the Iterator. */
+func Open(path string, name string, options Options) (Iterator, io.Closer, error) {
+	var format Format
+	var err error
+	if name == "" {
+		format, err = ForPath(path)
+	} else {
+		format, err = Get(name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parser, err := format.New(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parser.Parse(file), file, nil
+}
+
+/* Read every Entry left in `iterator`. */
+func ReadAll(iterator Iterator) ([]*Entry, error) {
+	entries := []*Entry{}
+	for {
+		entry, err := iterator.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+/* ParseError is a problem with the file itself, rather than reading it. */
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (this *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", this.Line, this.Message)
+}
+
+/* Options for a format that doesn't take any. */
+func noOptions(name string, options Options) error {
+	for key := range options {
+		return fmt.Errorf("The %s format doesn't take a %q option", name, key)
+	}
+	return nil
+}
+
+/* Look up the text encoding called `name` ("latin1", "windows-1252",
+ * "utf-16le" and so on, as a web browser would know them). An empty name
+ * is UTF-8. */
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return unicode.UTF8, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown encoding %q", name)
+	}
+	return enc, nil
+}
+
+/* Get a reader over `reader`, turning text in the encoding `enc` into
+ * UTF-8. A byte order mark, if there is one, wins over `enc`, since
+ * spreadsheets like to put them on the front of everything. */
+func decodeReader(reader io.Reader, enc encoding.Encoding) io.Reader {
+	return transform.NewReader(reader, unicode.BOMOverride(enc.NewDecoder()))
+}
+
+/* How long a line we'll read, since bufio.Scanner's default of 64k is a
+ * bit short for some dictionaries. */
+const maxLineLength = 16 * 1024 * 1024
+
+/* Get a Scanner over the lines of `reader`, which can cope with long ones. */
+func newLineScanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	return scanner
+}
@@ -0,0 +1,146 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/dictd/dictd"
+)
+
+/* Parse `text` in the format `name`, failing the test if that goes wrong. */
+func parseString(t *testing.T, name string, options Options, text string) []*Entry {
+	format, err := Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := format.New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadAll(parser.Parse(strings.NewReader(text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestJargonParser(t *testing.T) {
+	entries := parseString(t, "jargon", nil, "preamble\n:foo: a thing\n more\n:bar: another: thing\n")
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+
+	if entries[0].Word != "foo" || entries[0].Definition != "a thing\r\n more" {
+		t.Errorf("Bad first entry")
+	}
+
+	if entries[1].Word != "bar" || entries[1].Definition != "another: thing" {
+		t.Errorf("Bad last entry")
+	}
+}
+
+func TestJargonParserEmpty(t *testing.T) {
+	if len(parseString(t, "jargon", nil, "")) != 0 {
+		t.Errorf("Got entries out of nothing")
+	}
+}
+
+func TestJargonRoundTrip(t *testing.T) {
+	buffer := bytes.Buffer{}
+	writer := NewJargonWriter(&buffer)
+	writer.Write((&Entry{Word: "foo", Definition: "a thing\r\n:not: a word"}).AsDefinition())
+	writer.Close()
+
+	entries := parseString(t, "jargon", nil, buffer.String())
+	if len(entries) != 1 {
+		t.Fatalf("Continuation line started a new entry")
+	}
+}
+
+func TestUnknownOption(t *testing.T) {
+	format, _ := Get("jargon")
+	if _, err := format.New(Options{"nope": "1"}); err == nil {
+		t.Errorf("Took an option we don't understand")
+	}
+}
+
+func TestDictdNumber(t *testing.T) {
+	if dictdNumber(0) != "A" || dictdNumber(63) != "/" || dictdNumber(64) != "BA" {
+		t.Errorf("Bad dictd number encoding")
+	}
+}
+
+func TestDictdWriter(t *testing.T) {
+	index := bytes.Buffer{}
+	dict := bytes.Buffer{}
+	writer := NewDictdWriter(&index, &dict)
+	for _, word := range []string{"hack", "Hacker Ethic", "hack-value", "ha ha only serious", "Zork", "ångström"} {
+		if err := writer.Write(&dictd.Definition{Word: word, Definition: "A word."}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	words := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(index.String(), "\n"), "\n") {
+		words = append(words, strings.Split(line, "\t")[0])
+	}
+	expected := []string{
+		"00-database-allchars", "00-database-utf8",
+		"ha ha only serious", "hack", "hack-value", "Hacker Ethic", "Zork", "ångström",
+	}
+	if strings.Join(words, "|") != strings.Join(expected, "|") {
+		t.Errorf("Bad index order %q", words)
+	}
+}
+
+func TestJargonStructure(t *testing.T) {
+	entries := parseString(t, "jargon", nil, `:kluge: /klooj/, n.,vt.
+   1. A Rube Goldberg device, see {hack}. Takes version 2. of
+   the {hack}. 2. Something that works, for some value of {works}.
+`)
+
+	entry := entries[0]
+	if entry.Pronunciation != "klooj" {
+		t.Errorf("Bad pronunciation %q", entry.Pronunciation)
+	}
+
+	if strings.Join(entry.PartsOfSpeech, " ") != "n. vt." {
+		t.Errorf("Bad parts of speech %q", entry.PartsOfSpeech)
+	}
+
+	if len(entry.Senses) != 2 || !strings.HasPrefix(entry.Senses[1], "Something") {
+		t.Errorf("Bad senses %q", entry.Senses)
+	}
+
+	if strings.Join(entry.References, ",") != "hack,works" {
+		t.Errorf("Bad references %q", entry.References)
+	}
+
+	if !strings.HasPrefix(entry.Definition, "/klooj/, n.,vt.\r\n   1. A Rube") {
+		t.Errorf("Plain text definition changed")
+	}
+}
+
+func TestJargonRenderSenses(t *testing.T) {
+	entries := parseString(t, "jargon", Options{"render": "senses"},
+		":foo: n. 1. One thing. 2. Another.\n")
+
+	if entries[0].Definition != "n.\r\n\r\n1. One thing.\r\n2. Another." {
+		t.Errorf("Bad rendering %q", entries[0].Definition)
+	}
+}
+
+func TestJargonParseError(t *testing.T) {
+	format, _ := Get("jargon")
+	parser, _ := format.New(nil)
+	_, err := ReadAll(parser.Parse(strings.NewReader(":foo: bar\n\n:: baz\n")))
+
+	if parseError, ok := err.(*ParseError); !ok || parseError.Line != 3 {
+		t.Errorf("Didn't get a parse error for line 3")
+	}
+}
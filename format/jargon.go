@@ -2,52 +2,324 @@ package format
 
 import (
 	"bufio"
+	"fmt"
+	"io"
 	"log"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"pault.ag/go/dictd/dictd"
 )
 
+func init() {
+	Register(Format{
+		Name:       "jargon",
+		Extensions: []string{".jargon"},
+		Detect: func(line string) bool {
+			return strings.HasPrefix(line, ":") && strings.Count(line, ":") >= 2
+		},
+		New: func(options Options) (Parser, error) {
+			return NewJargonParser(options)
+		},
+	})
+}
+
+/* Parse the Jargon File formatted dictionary at `path`, giving up (and
+ * taking the program with us) if we can't read it. */
 func ParseJargonFormat(path string) []*dictd.Definition {
-	file, err := os.Open(path)
+	iterator, file, err := Open(path, "jargon", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer file.Close()
 
-	var def = ""
-	var word = ""
-	var defs = make([]*dictd.Definition, 0)
+	entries, err := ReadAll(iterator)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defs := make([]*dictd.Definition, len(entries))
+	for i, el := range entries {
+		defs[i] = el.AsDefinition()
+	}
+	return defs
+}
+
+/* JargonParser reads the Jargon File's format, where each entry starts
+ * with a ":word: " line, and carries on until the next one. The header
+ * line usually has a pronunciation and part of speech after the word,
+ *
+ *   :kluge: /klooj/, n.,vt.
+ *
+ * and the body is split into numbered senses, with {braces} around the
+ * names of other entries.
+ *
+ * The Definition is the text as it was in the file. Setting the "render"
+ * option to "senses" replaces it with one built from the structure, one
+ * sense per line. */
+type JargonParser struct {
+	renderSenses bool
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+func NewJargonParser(options Options) (*JargonParser, error) {
+	parser := JargonParser{}
+	for key, value := range options {
+		switch {
+		case key == "render" && value == "text":
+		case key == "render" && value == "senses":
+			parser.renderSenses = true
+		case key == "render":
+			return nil, fmt.Errorf("The jargon format can render text or senses, not %q", value)
+		default:
+			return nil, fmt.Errorf("The jargon format doesn't take a %q option", key)
+		}
+	}
+	return &parser, nil
+}
+
+func (this *JargonParser) Parse(reader io.Reader) Iterator {
+	return &jargonIterator{
+		parser:  this,
+		scanner: newLineScanner(reader),
+	}
+}
+
+type jargonIterator struct {
+	parser  *JargonParser
+	scanner *bufio.Scanner
+	line    int
+
+	/* The entry we're in the middle of reading. */
+	word   string
+	header string
+	body   []string
+	done   bool
+}
+
+func (this *jargonIterator) Next() (*Entry, error) {
+	for !this.done && this.scanner.Scan() {
+		this.line++
+		line := this.scanner.Text()
 		if strings.HasPrefix(line, ":") {
 			tokens := strings.SplitN(line, ":", 3)
 			if len(tokens) == 3 {
-				if word != "" {
-					defs = append(defs, &dictd.Definition{
-						Word:       word,
-						Definition: def,
-					})
+				word := strings.Trim(tokens[1], " \t\n\r")
+				if word == "" {
+					return nil, &ParseError{Line: this.line, Message: "Entry with no headword"}
+				}
+
+				entry := this.entry()
+				this.word = word
+				this.header = strings.Trim(tokens[2], " \t\n\r")
+				this.body = []string{}
+				if entry != nil {
+					return entry, nil
 				}
-				word = strings.Trim(tokens[1], " \t\n\r")
-				def = strings.Trim(tokens[2], " \t\n\r")
 				continue
 			}
 		}
-		def = def + "\r\n" + line
+		this.body = append(this.body, line)
 	}
 
-	defs = append(defs, &dictd.Definition{
-		Word:       word,
-		Definition: def,
-	})
+	if err := this.scanner.Err(); err != nil {
+		return nil, &ParseError{Line: this.line + 1, Message: err.Error()}
+	}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+	/* Out of lines, so whatever we've got is the last one. */
+	this.done = true
+	if entry := this.entry(); entry != nil {
+		this.word = ""
+		return entry, nil
 	}
+	return nil, io.EOF
+}
 
-	return defs
+/* The entry read so far, or nil if we've not started one. */
+func (this *jargonIterator) entry() *Entry {
+	if this.word == "" {
+		return nil
+	}
+
+	entry := Entry{
+		Word:       this.word,
+		Definition: strings.Join(append([]string{this.header}, this.body...), "\r\n"),
+	}
+
+	rest := this.header
+	entry.Pronunciation, rest = jargonPronunciation(rest)
+	entry.PartsOfSpeech, rest = jargonPartsOfSpeech(rest)
+
+	text := strings.Join(strings.Fields(rest+" "+strings.Join(this.body, " ")), " ")
+	entry.Senses = jargonSenses(text)
+	entry.References = jargonReferences(text)
+
+	if this.parser.renderSenses {
+		entry.Definition = renderJargonSenses(&entry)
+	}
+	return &entry
+}
+
+/* Pull the /pronunciation/ off the front of the header `header`, and hand
+ * back what's left. */
+func jargonPronunciation(header string) (string, string) {
+	if !strings.HasPrefix(header, "/") {
+		return "", header
+	}
+	end := strings.Index(header[1:], "/")
+	if end < 0 {
+		return "", header
+	}
+	return header[1 : end+1], strings.TrimLeft(header[end+2:], " ,")
+}
+
+/* Abbreviations the Jargon File uses for parts of speech. */
+var jargonParts = map[string]bool{
+	"n.": true, "v.": true, "vt.": true, "vi.": true, "adj.": true,
+	"adv.": true, "interj.": true, "pref.": true, "suff.": true,
+	"abbrev.": true, "prep.": true, "conj.": true, "pron.": true,
+	"excl.": true, "quant.": true, "num.": true,
+}
+
+/* Pull the parts of speech ("n.,vt.") off the front of `text`, and hand
+ * back what's left. */
+func jargonPartsOfSpeech(text string) ([]string, string) {
+	parts := []string{}
+	for {
+		text = strings.TrimLeft(text, " ,")
+		found := false
+		for part := range jargonParts {
+			rest := strings.TrimPrefix(text, part)
+			if rest != text && (rest == "" || strings.ContainsAny(rest[:1], " ,")) {
+				parts = append(parts, part)
+				text = text[len(part):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return parts, text
+		}
+	}
+}
+
+/* Where a numbered sense ("1. ", "2. ") might start. */
+var jargonSenseNumber = regexp.MustCompile(`(^|\s)(\d+)\. `)
+
+/* Split `text` into its numbered senses. Numbers have to go up one at a
+ * time from 1, and come at the start of a sentence, so "version 2. " in
+ * the middle of a sense doesn't count. */
+func jargonSenses(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+
+	starts := [][]int{}
+	next := 1
+	for _, match := range jargonSenseNumber.FindAllStringSubmatchIndex(text, -1) {
+		before := strings.TrimRight(text[:match[4]], " ")
+		if before != "" && !strings.ContainsAny(before[len(before)-1:], ".:;])") {
+			continue
+		}
+		if text[match[4]:match[5]] == strconv.Itoa(next) {
+			starts = append(starts, []int{match[4], match[1]})
+			next++
+		}
+	}
+
+	if len(starts) == 0 {
+		return []string{text}
+	}
+
+	senses := []string{}
+	for i, el := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		senses = append(senses, strings.TrimSpace(text[el[1]:end]))
+	}
+	return senses
+}
+
+/* A {cross-reference} to another entry. */
+var jargonReference = regexp.MustCompile(`\{([^{}]+)\}`)
+
+/* Find the entries `text` refers to, in order, without repeats. */
+func jargonReferences(text string) []string {
+	references := []string{}
+	seen := map[string]bool{}
+	for _, match := range jargonReference.FindAllStringSubmatch(text, -1) {
+		reference := strings.TrimSpace(match[1])
+		if reference != "" && !seen[reference] {
+			seen[reference] = true
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+/* Write the Entry `entry` back out as text, from its structured fields. */
+func renderJargonSenses(entry *Entry) string {
+	lines := []string{}
+
+	header := []string{}
+	if entry.Pronunciation != "" {
+		header = append(header, "/"+entry.Pronunciation+"/")
+	}
+	if len(entry.PartsOfSpeech) > 0 {
+		header = append(header, strings.Join(entry.PartsOfSpeech, ","))
+	}
+	if len(header) > 0 {
+		lines = append(lines, strings.Join(header, ", "), "")
+	}
+
+	if len(entry.Senses) == 1 {
+		lines = append(lines, entry.Senses[0])
+	} else {
+		for i, sense := range entry.Senses {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, sense))
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+/* JargonWriter writes Definitions out in the format ParseJargonFormat
+ * reads: a ":word: " line starting each entry, and the rest of the
+ * definition on the lines after it. */
+type JargonWriter struct {
+	writer *bufio.Writer
+}
+
+func NewJargonWriter(writer io.Writer) *JargonWriter {
+	return &JargonWriter{writer: bufio.NewWriter(writer)}
+}
+
+func (this *JargonWriter) Write(definition *dictd.Definition) error {
+	if strings.ContainsAny(definition.Word, ":\r\n") {
+		return fmt.Errorf("Can't write %q in the Jargon format", definition.Word)
+	}
+
+	text := strings.Replace(definition.Body(), "\r\n", "\n", -1)
+	lines := strings.Split(text, "\n")
+
+	if _, err := fmt.Fprintf(this.writer, ":%s: %s\n", definition.Word, lines[0]); err != nil {
+		return err
+	}
+
+	for _, line := range lines[1:] {
+		/* A line that looks like ":foo: bar" would start a new entry
+		 * when it's read back in, so push it over a bit. */
+		if strings.HasPrefix(line, ":") && strings.Count(line, ":") >= 2 {
+			line = " " + line
+		}
+		if _, err := fmt.Fprintf(this.writer, "%s\n", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *JargonWriter) Close() error {
+	return this.writer.Flush()
 }
@@ -0,0 +1,239 @@
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"pault.ag/go/dictd/dictd"
+)
+
+func init() {
+	Register(Format{
+		Name:       "jsonl",
+		Extensions: []string{".jsonl", ".ndjson"},
+		Detect: func(line string) bool {
+			return strings.HasPrefix(line, "{")
+		},
+		New: func(options Options) (Parser, error) {
+			return NewJSONLinesParser(options)
+		},
+	})
+}
+
+/* JSONLinesParser reads JSON Lines, one JSON object per line, which is
+ * what JSONLinesWriter writes, and what most things can export. Which
+ * fields end up where is set by options, each a path to the field, with
+ * dots between the names of nested objects ("fields.term"), and numbers
+ * for array indexes ("titles.0"):
+ *
+ *   word          - the headword (default "word").
+ *   definition    - the definition (default "definition").
+ *   pronunciation - the pronunciation (default "pronunciation").
+ *   pos           - the part(s) of speech (default "pos").
+ *   source        - where the entry came from (default "source").
+ *   modified      - when the entry last changed, as an RFC 3339 time
+ *                   (default "modified").
+ *   senses, examples
+ *                 - lists of strings (or a single string) for those
+ *                   (default "senses" and "examples").
+ *   synonyms, hypernyms, references, translations
+ *                 - the same, for related words (default
+ *                   "related.synonym" and so on, as JSONLinesWriter
+ *                   writes them).
+ *
+ * If there's no definition, but there are senses, the definition is the
+ * senses, one per line. */
+type JSONLinesParser struct {
+	paths map[string][]string
+}
+
+/* Options JSONLinesParser understands, and their default paths. */
+var jsonLinesFields = map[string]string{
+	"word":          "word",
+	"definition":    "definition",
+	"pronunciation": "pronunciation",
+	"pos":           "pos",
+	"senses":        "senses",
+	"examples":      "examples",
+	"source":        "source",
+	"modified":      "modified",
+	"synonyms":      "related.synonym",
+	"hypernyms":     "related.hypernym",
+	"references":    "related.see-also",
+	"translations":  "related.translation",
+}
+
+func NewJSONLinesParser(options Options) (*JSONLinesParser, error) {
+	parser := JSONLinesParser{paths: map[string][]string{}}
+	for field, path := range jsonLinesFields {
+		if value, ok := options[field]; ok {
+			path = value
+		}
+		if path != "" {
+			parser.paths[field] = strings.Split(path, ".")
+		}
+	}
+	for key := range options {
+		if _, ok := jsonLinesFields[key]; !ok {
+			return nil, fmt.Errorf("The jsonl format doesn't take a %q option", key)
+		}
+	}
+	return &parser, nil
+}
+
+func (this *JSONLinesParser) Parse(reader io.Reader) Iterator {
+	return &jsonLinesIterator{
+		parser:  this,
+		scanner: newLineScanner(reader),
+	}
+}
+
+type jsonLinesIterator struct {
+	parser  *JSONLinesParser
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (this *jsonLinesIterator) Next() (*Entry, error) {
+	for this.scanner.Scan() {
+		this.line++
+		text := strings.TrimSpace(this.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var object interface{}
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return nil, &ParseError{Line: this.line, Message: err.Error()}
+		}
+
+		entry := Entry{}
+		list := func(field string) []string {
+			return jsonStrings(jsonPath(object, this.parser.paths[field]))
+		}
+		first := func(field string) string {
+			if values := list(field); len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		}
+
+		entry.Word = first("word")
+		if entry.Word == "" {
+			return nil, &ParseError{Line: this.line, Message: "Object with no headword"}
+		}
+		entry.Definition = first("definition")
+		entry.Pronunciation = first("pronunciation")
+		entry.PartsOfSpeech = list("pos")
+		entry.Senses = list("senses")
+		entry.Examples = list("examples")
+		entry.Synonyms = list("synonyms")
+		entry.References = list("references")
+		entry.Hypernyms = list("hypernyms")
+		entry.Translations = list("translations")
+		entry.Source = first("source")
+
+		if modified := first("modified"); modified != "" {
+			when, err := time.Parse(time.RFC3339, modified)
+			if err != nil {
+				return nil, &ParseError{Line: this.line, Message: "Bad modified time " + strconv.Quote(modified)}
+			}
+			entry.Modified = &when
+		}
+
+		if entry.Definition == "" && len(entry.Senses) > 0 {
+			lines := []string{}
+			for i, sense := range entry.Senses {
+				lines = append(lines, fmt.Sprintf("%d. %s", i+1, sense))
+			}
+			entry.Definition = strings.Join(lines, "\r\n")
+		}
+		return &entry, nil
+	}
+
+	if err := this.scanner.Err(); err != nil {
+		return nil, &ParseError{Line: this.line + 1, Message: err.Error()}
+	}
+	return nil, io.EOF
+}
+
+/* JSONLinesWriter writes Definitions out as JSON Lines, one object per
+ * line, with a "word", a "definition", and whatever structured fields the
+ * Definition has. JSONLinesParser reads them back in as they are. */
+type JSONLinesWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func NewJSONLinesWriter(writer io.Writer) *JSONLinesWriter {
+	buffered := bufio.NewWriter(writer)
+	return &JSONLinesWriter{
+		writer:  buffered,
+		encoder: json.NewEncoder(buffered),
+	}
+}
+
+func (this *JSONLinesWriter) Write(definition *dictd.Definition) error {
+	line := *definition
+	line.Definition = definition.Body()
+	line.DictDatabaseName = ""
+
+	/* Encode tacks the newline on for us. */
+	return this.encoder.Encode(&line)
+}
+
+func (this *JSONLinesWriter) Close() error {
+	return this.writer.Flush()
+}
+
+/* Follow the path `path` down into the decoded JSON `value`, or return
+ * nil if it's not there. */
+func jsonPath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return nil
+	}
+	for _, el := range path {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[el]
+		case []interface{}:
+			index, err := strconv.Atoi(el)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil
+			}
+			value = node[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+/* Turn a decoded JSON value into a list of strings: a string is a list of
+ * one, and numbers and bools are written out as they were. */
+func jsonStrings(value interface{}) []string {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	case []interface{}:
+		ret := []string{}
+		for _, el := range value {
+			ret = append(ret, jsonStrings(el)...)
+		}
+		return ret
+	case map[string]interface{}:
+		return nil
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
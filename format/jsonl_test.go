@@ -0,0 +1,68 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLinesRoundTrip(t *testing.T) {
+	buffer := bytes.Buffer{}
+	writer := NewJSONLinesWriter(&buffer)
+	writer.Write((&Entry{Word: "foo", Definition: "a thing"}).AsDefinition())
+	writer.Close()
+
+	entries := parseString(t, "jsonl", nil, buffer.String())
+	if len(entries) != 1 || entries[0].Word != "foo" || entries[0].Definition != "a thing" {
+		t.Errorf("Bad round trip")
+	}
+}
+
+func TestJSONLinesModified(t *testing.T) {
+	modified := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	buffer := bytes.Buffer{}
+	writer := NewJSONLinesWriter(&buffer)
+	writer.Write((&Entry{Word: "foo", Definition: "a thing", Modified: &modified}).AsDefinition())
+	writer.Close()
+
+	entries := parseString(t, "jsonl", nil, buffer.String())
+	if len(entries) != 1 || entries[0].Modified == nil || !entries[0].Modified.Equal(modified) {
+		t.Fatalf("Modified time didn't survive the round trip")
+	}
+	if !strings.Contains(entries[0].AsDefinition().Text(), "Last modified: 2015-06-01") {
+		t.Errorf("Modified time isn't rendered")
+	}
+}
+
+func TestJSONLinesPaths(t *testing.T) {
+	entries := parseString(t, "jsonl", Options{
+		"word":     "fields.term",
+		"senses":   "fields.meanings",
+		"synonyms": "tags.0",
+	}, `{"fields": {"term": "SLA", "meanings": ["one", "two"]}, "tags": [["uptime"]]}`+"\n")
+
+	entry := entries[0]
+	if entry.Word != "SLA" || entry.Definition != "1. one\r\n2. two" {
+		t.Errorf("Bad nested fields %q", entry.Definition)
+	}
+
+	if strings.Join(entry.Synonyms, ",") != "uptime" {
+		t.Errorf("Bad array index path")
+	}
+}
+
+func TestJSONLinesErrors(t *testing.T) {
+	format, _ := Get("jsonl")
+	parser, _ := format.New(nil)
+	_, err := ReadAll(parser.Parse(strings.NewReader("{\"word\": \"a\"}\n\n{\"word\": \n")))
+
+	if parseError, ok := err.(*ParseError); !ok || parseError.Line != 3 {
+		t.Errorf("Didn't get a parse error for line 3: %v", err)
+	}
+
+	if _, err := format.New(Options{"nope": "x"}); err == nil {
+		t.Errorf("Took an option we don't understand")
+	}
+}
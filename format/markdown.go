@@ -0,0 +1,290 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(Format{
+		Name:       "markdown",
+		Extensions: []string{".md", ".markdown"},
+		New: func(options Options) (Parser, error) {
+			return NewMarkdownParser(options)
+		},
+	})
+}
+
+/* MarkdownParser reads glossaries written in Markdown (like the
+ * GLOSSARY.md files a lot of projects keep). Terms are either headings,
+ *
+ *   ## SLA
+ *
+ *   Service level agreement. See [uptime](#uptime).
+ *
+ * or definition lists,
+ *
+ *   SLA
+ *   : Service level agreement.
+ *
+ * Links to other parts of the glossary (or other relative paths) become
+ * {cross-references}; links off to the web keep their URL, as
+ * "text <url>". The "level" option sets which headings are terms (2, for
+ * "##", by default); lower level headings are left alone, and higher
+ * level ones are part of the definition. */
+type MarkdownParser struct {
+	level int
+}
+
+func NewMarkdownParser(options Options) (*MarkdownParser, error) {
+	parser := MarkdownParser{level: 2}
+	for key, value := range options {
+		switch key {
+		case "level":
+			level, err := strconv.Atoi(value)
+			if err != nil || level < 1 || level > 6 {
+				return nil, fmt.Errorf("The heading level has to be 1 to 6, not %q", value)
+			}
+			parser.level = level
+		default:
+			return nil, fmt.Errorf("The markdown format doesn't take a %q option", key)
+		}
+	}
+	return &parser, nil
+}
+
+func (this *MarkdownParser) Parse(reader io.Reader) Iterator {
+	return &markdownIterator{
+		parser:  this,
+		scanner: newLineScanner(reader),
+	}
+}
+
+/* An entry we're still reading the lines of. */
+type markdownEntry struct {
+	word string
+
+	/* From a definition list, rather than a heading. */
+	list bool
+
+	lines  []string
+	senses []string
+}
+
+type markdownIterator struct {
+	parser  *MarkdownParser
+	scanner *bufio.Scanner
+	line    int
+
+	current *markdownEntry
+
+	/* The line before this one, and if it was part of a definition. */
+	previous   string
+	definition bool
+
+	/* Inside a ``` block, where nothing means anything. */
+	fence bool
+
+	/* Entries that are done, waiting to go out. */
+	ready []*Entry
+	done  bool
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	markdownFence   = regexp.MustCompile("^\\s*(```|~~~)")
+	markdownDefines = regexp.MustCompile(`^:\s+(.*)$`)
+)
+
+func (this *markdownIterator) Next() (*Entry, error) {
+	for len(this.ready) == 0 && !this.done {
+		if !this.scanner.Scan() {
+			if err := this.scanner.Err(); err != nil {
+				return nil, &ParseError{Line: this.line + 1, Message: err.Error()}
+			}
+			this.finish()
+			this.done = true
+			break
+		}
+		this.line++
+		if err := this.read(this.scanner.Text()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(this.ready) == 0 {
+		return nil, io.EOF
+	}
+	entry := this.ready[0]
+	this.ready = this.ready[1:]
+	return entry, nil
+}
+
+/* Handle the line `line`. */
+func (this *markdownIterator) read(line string) error {
+	previous := this.previous
+	this.previous = line
+
+	if markdownFence.MatchString(line) {
+		this.fence = !this.fence
+		this.add(line)
+		return nil
+	}
+	if this.fence {
+		this.add(line)
+		return nil
+	}
+
+	if match := markdownHeading.FindStringSubmatch(line); match != nil {
+		level := len(match[1])
+		switch {
+		case level == this.parser.level:
+			this.finish()
+			word := markdownPlain(match[2])
+			if word == "" {
+				return &ParseError{Line: this.line, Message: "Heading with no term"}
+			}
+			this.current = &markdownEntry{word: word}
+			return nil
+		case level < this.parser.level:
+			this.finish()
+			return nil
+		}
+	}
+
+	if match := markdownDefines.FindStringSubmatch(line); match != nil {
+		/* Another definition of the term we're already on. */
+		if this.current != nil && this.current.list && this.definition {
+			this.current.senses = append(this.current.senses, match[1])
+			this.current.lines = append(this.current.lines, "", match[1])
+			return nil
+		}
+
+		term := strings.TrimSpace(previous)
+		if term == "" || strings.HasPrefix(previous, " ") {
+			return &ParseError{Line: this.line, Message: "Definition with no term"}
+		}
+
+		/* The term went in as part of whatever we were on before. */
+		if this.current != nil && !this.current.list && len(this.current.lines) > 0 {
+			this.current.lines = this.current.lines[:len(this.current.lines)-1]
+		}
+		this.finish()
+
+		this.current = &markdownEntry{
+			word:   markdownPlain(term),
+			list:   true,
+			lines:  []string{match[1]},
+			senses: []string{match[1]},
+		}
+		this.definition = true
+		return nil
+	}
+
+	if this.current != nil && this.current.list {
+		switch {
+		case strings.TrimSpace(line) == "":
+			this.current.lines = append(this.current.lines, "")
+		case this.definition && (strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")):
+			text := strings.TrimSpace(line)
+			this.current.lines = append(this.current.lines, text)
+			last := len(this.current.senses) - 1
+			this.current.senses[last] += " " + text
+		default:
+			/* Back to normal text; this might be the next term. */
+			this.finish()
+		}
+		return nil
+	}
+
+	this.add(line)
+	return nil
+}
+
+/* Add `line` to the definition we're reading, if we're reading one. */
+func (this *markdownIterator) add(line string) {
+	if this.current != nil {
+		this.current.lines = append(this.current.lines, line)
+	}
+}
+
+/* Turn the entry we've been reading into an Entry, and queue it up. */
+func (this *markdownIterator) finish() {
+	current := this.current
+	this.current = nil
+	this.definition = false
+	if current == nil {
+		return
+	}
+
+	text := strings.Trim(strings.Join(current.lines, "\n"), "\n")
+	definition, references := markdownInline(text)
+
+	entry := Entry{
+		Word:       current.word,
+		Definition: strings.Replace(definition, "\n", "\r\n", -1),
+		References: references,
+		Senses:     []string{},
+	}
+
+	senses := current.senses
+	if !current.list && text != "" {
+		senses = []string{text}
+	}
+	for _, el := range senses {
+		sense, _ := markdownInline(el)
+		entry.Senses = append(entry.Senses, strings.Join(strings.Fields(sense), " "))
+	}
+
+	this.ready = append(this.ready, &entry)
+}
+
+var (
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]+)\]\(\s*([^)\s]*)(?:\s+"[^"]*")?\s*\)`)
+	markdownRefLink  = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	markdownStrong   = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	markdownEmphasis = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	markdownCode     = regexp.MustCompile("`([^`]+)`")
+)
+
+/* Turn the inline Markdown in `text` into plain text, with links to other
+ * parts of the glossary as {cross-references}, which we also hand back. */
+func markdownInline(text string) (string, []string) {
+	references := []string{}
+
+	text = markdownImage.ReplaceAllString(text, "$1")
+	text = markdownLink.ReplaceAllStringFunc(text, func(link string) string {
+		match := markdownLink.FindStringSubmatch(link)
+		label, url := strings.TrimSpace(match[1]), match[2]
+		if strings.Contains(url, "://") || strings.HasPrefix(url, "mailto:") {
+			return label + " <" + url + ">"
+		}
+		if !containsString(references, label) {
+			references = append(references, label)
+		}
+		return "{" + label + "}"
+	})
+	text = markdownRefLink.ReplaceAllStringFunc(text, func(link string) string {
+		label := strings.TrimSpace(markdownRefLink.FindStringSubmatch(link)[1])
+		if !containsString(references, label) {
+			references = append(references, label)
+		}
+		return "{" + label + "}"
+	})
+	text = markdownStrong.ReplaceAllString(text, "$2")
+	text = markdownEmphasis.ReplaceAllString(text, "$1")
+	text = markdownCode.ReplaceAllString(text, "$1")
+	return text, references
+}
+
+/* Turn the inline Markdown in `text` into plain text, links and all. */
+func markdownPlain(text string) string {
+	text, _ = markdownInline(text)
+	text = strings.NewReplacer("{", "", "}", "").Replace(text)
+	return strings.TrimSpace(text)
+}
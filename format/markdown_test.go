@@ -0,0 +1,83 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownHeadings(t *testing.T) {
+	entries := parseString(t, "markdown", nil, "# Glossary\n\nIntro text.\n\n"+
+		"## SLA\n\nService level **agreement**. See [uptime](#uptime) and\n[the docs](https://example.com/sla).\n\n"+
+		"### Notes\n\n```\n## not a term\n```\n\n"+
+		"## `uptime`\n\nHow long it's been up.\n")
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+
+	sla := entries[0]
+	if sla.Word != "SLA" {
+		t.Errorf("Bad headword %q", sla.Word)
+	}
+
+	if !strings.HasPrefix(sla.Definition, "Service level agreement. See {uptime} and\r\nthe docs <https://example.com/sla>.") {
+		t.Errorf("Bad definition %q", sla.Definition)
+	}
+
+	if !strings.Contains(sla.Definition, "## not a term") {
+		t.Errorf("Lost the code block")
+	}
+
+	if strings.Join(sla.References, ",") != "uptime" {
+		t.Errorf("Bad references %q", sla.References)
+	}
+
+	if entries[1].Word != "uptime" {
+		t.Errorf("Bad code span headword %q", entries[1].Word)
+	}
+}
+
+func TestMarkdownClosingHashes(t *testing.T) {
+	entries := parseString(t, "markdown", nil, "## C#\n\nA language.\n\n"+
+		"## F# ##\n\nAnother, like {C#}.\n")
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+	if entries[0].Word != "C#" {
+		t.Errorf("Bad headword %q", entries[0].Word)
+	}
+	if entries[1].Word != "F#" {
+		t.Errorf("Didn't drop the closing hashes: %q", entries[1].Word)
+	}
+}
+
+func TestMarkdownDefinitionLists(t *testing.T) {
+	entries := parseString(t, "markdown", nil, "Some prose.\n\n"+
+		"SLA\n: Service level agreement,\n  the promise.\n: A [contract](contracts.md).\n\n"+
+		"RFC\n: Request for comments.\n\nMore prose.\n")
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+
+	if entries[0].Word != "SLA" || len(entries[0].Senses) != 2 {
+		t.Errorf("Bad first entry %q", entries[0].Senses)
+	}
+
+	if entries[0].Senses[0] != "Service level agreement, the promise." || entries[0].Senses[1] != "A {contract}." {
+		t.Errorf("Bad senses %q", entries[0].Senses)
+	}
+
+	if entries[1].Word != "RFC" || entries[1].Definition != "Request for comments." {
+		t.Errorf("Bad last entry %q", entries[1].Definition)
+	}
+}
+
+func TestMarkdownLevel(t *testing.T) {
+	entries := parseString(t, "markdown", Options{"level": "3"}, "## Section\n\n### SLA\n\nAgreement.\n")
+
+	if len(entries) != 1 || entries[0].Word != "SLA" {
+		t.Errorf("Didn't use the level option")
+	}
+}
@@ -0,0 +1,116 @@
+package format
+
+import (
+	"io"
+	"strings"
+)
+
+func init() {
+	Register(Format{
+		Name:       "tei",
+		Extensions: []string{".tei"},
+		Detect: func(line string) bool {
+			return strings.HasPrefix(line, "<TEI")
+		},
+		New: func(options Options) (Parser, error) {
+			return &TEIParser{}, noOptions("tei", options)
+		},
+	})
+}
+
+/* TEIParser reads TEI dictionaries, which is what FreeDict's sources are
+ * written in. Each <entry> becomes an Entry:
+ *
+ *   <entry>
+ *     <form><orth>dog</orth><pron>dɒg</pron></form>
+ *     <gramGrp><pos>n</pos></gramGrp>
+ *     <sense><cit type="trans"><quote>Hund</quote></cit></sense>
+ *   </entry>
+ *
+ * We understand both the current (P5) way of writing translations, as
+ * above, and the older (P4) <trans><tr>Hund</tr></trans>. */
+type TEIParser struct{}
+
+func (this *TEIParser) Parse(reader io.Reader) Iterator {
+	return newXMLIterator(reader, "entry", teiEntry)
+}
+
+/* Turn a TEI <entry> into an Entry. */
+func teiEntry(node *xmlNode) *Entry {
+	entry := Entry{}
+
+	orths := node.Texts("orth", "sense")
+	if len(orths) > 0 {
+		entry.Word = orths[0]
+		entry.Synonyms = orths[1:]
+	}
+	if prons := node.Texts("pron", "sense"); len(prons) > 0 {
+		entry.Pronunciation = prons[0]
+	}
+	entry.PartsOfSpeech = node.Texts("pos")
+	entry.References = node.Texts("ref")
+
+	entry.Senses = []string{}
+	for _, sense := range node.Find("sense") {
+		teiSenses(&entry, sense)
+	}
+
+	/* P4 entries, and P5 ones without senses, have everything at the
+	 * top level. */
+	if len(entry.Senses) == 0 {
+		teiSense(&entry, node)
+	}
+
+	entry.Definition = renderXMLEntry(&entry)
+	return &entry
+}
+
+/* Add the <sense> `sense`, and any senses inside it, to `entry`. */
+func teiSenses(entry *Entry, sense *xmlNode) {
+	teiSense(entry, sense)
+	for _, el := range sense.Find("sense") {
+		teiSenses(entry, el)
+	}
+}
+
+/* Add what's directly in `node` (leaving any <sense>s inside it alone) to
+ * `entry` as a sense. */
+func teiSense(entry *Entry, node *xmlNode) {
+	parts := []string{}
+
+	usages := node.Texts("usg", "sense")
+	translations := []string{}
+	for _, cit := range node.Find("cit", "sense") {
+		quote := strings.Join(cit.Texts("quote"), "; ")
+		if quote == "" {
+			continue
+		}
+		switch cit.attrs["type"] {
+		case "trans", "translation":
+			translations = append(translations, quote)
+		case "example":
+			entry.Examples = append(entry.Examples, quote)
+		}
+	}
+	translations = append(translations, node.Texts("tr", "sense")...)
+	entry.Examples = append(entry.Examples, node.Texts("q", "sense", "cit")...)
+
+	if len(usages) > 0 {
+		parts = append(parts, "("+strings.Join(usages, ", ")+")")
+	}
+	if len(translations) > 0 {
+		parts = append(parts, strings.Join(translations, ", "))
+	}
+	for _, el := range translations {
+		if !containsString(entry.Translations, el) {
+			entry.Translations = append(entry.Translations, el)
+		}
+	}
+	if defs := node.Texts("def", "sense"); len(defs) > 0 {
+		parts = append(parts, strings.Join(defs, "; "))
+	}
+
+	if len(parts) > 0 {
+		entry.Senses = append(entry.Senses, strings.Join(parts, " "))
+	}
+}
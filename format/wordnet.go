@@ -0,0 +1,281 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(Format{
+		Name:       "wordnet",
+		Extensions: []string{".noun", ".verb", ".adj", ".adv"},
+		Detect: func(line string) bool {
+			return strings.HasPrefix(line, "1 This software and database is being provided")
+		},
+		New: func(options Options) (Parser, error) {
+			return &WordNetParser{}, noOptions("wordnet", options)
+		},
+	})
+}
+
+/* WordNetParser reads Princeton WordNet's data files (data.noun,
+ * data.verb, data.adj and data.adv), which have one synset (a set of
+ * words meaning the same thing) per line:
+ *
+ *   02084071 05 n 03 dog 0 domestic_dog 0 Canis_familiaris 0 023 @ 02083346 n 0000 ... | a member of the genus Canis ...; "the dog barked all night"
+ *
+ * Pointers (like the hypernym, "@", above) refer to other synsets by
+ * their offset into the file, and can point forwards, so the whole file
+ * is read in before any entries come out. Files can be concatenated, to
+ * get nouns, verbs and so on in one go.
+ *
+ * Each word gets one Entry, with a sense for every synset it's in. */
+type WordNetParser struct{}
+
+func (this *WordNetParser) Parse(reader io.Reader) Iterator {
+	return &wordNetIterator{reader: reader}
+}
+
+/* A single line of a data file. */
+type wordNetSynset struct {
+	partOfSpeech string
+	words        []string
+	hypernyms    []string
+	gloss        string
+	examples     []string
+}
+
+type wordNetIterator struct {
+	reader  io.Reader
+	entries []*Entry
+	read    bool
+}
+
+func (this *wordNetIterator) Next() (*Entry, error) {
+	if !this.read {
+		this.read = true
+		entries, err := readWordNet(this.reader)
+		if err != nil {
+			return nil, err
+		}
+		this.entries = entries
+	}
+
+	if len(this.entries) == 0 {
+		return nil, io.EOF
+	}
+	entry := this.entries[0]
+	this.entries = this.entries[1:]
+	return entry, nil
+}
+
+/* Names for WordNet's part of speech letters. Adjective satellites ("s")
+ * are just adjectives, as far as anyone reading them is concerned. */
+var wordNetPartsOfSpeech = map[string]string{
+	"n": "noun",
+	"v": "verb",
+	"a": "adj",
+	"s": "adj",
+	"r": "adv",
+}
+
+/* Synsets are known by their part of speech's file and their offset. */
+func wordNetKey(partOfSpeech string, offset string) string {
+	if partOfSpeech == "s" {
+		partOfSpeech = "a"
+	}
+	return partOfSpeech + offset
+}
+
+/* Read every synset in `reader`, and turn them into an Entry per word. */
+func readWordNet(reader io.Reader) ([]*Entry, error) {
+	synsets := map[string]*wordNetSynset{}
+	order := []*wordNetSynset{}
+	pointers := map[*wordNetSynset][]string{}
+
+	scanner := newLineScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		/* The license at the top of each file is indented. */
+		if strings.HasPrefix(text, " ") || strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		offset, synset, hypernyms, err := parseWordNetLine(text)
+		if err != nil {
+			return nil, &ParseError{Line: line, Message: err.Error()}
+		}
+		synsets[wordNetKey(synset.partOfSpeech, offset)] = synset
+		pointers[synset] = hypernyms
+		order = append(order, synset)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{Line: line + 1, Message: err.Error()}
+	}
+
+	/* Now everything's in, we can chase the pointers. */
+	for _, synset := range order {
+		for _, key := range pointers[synset] {
+			if target, ok := synsets[key]; ok {
+				synset.hypernyms = append(synset.hypernyms, target.words...)
+			}
+		}
+	}
+
+	entries := map[string]*Entry{}
+	ret := []*Entry{}
+	for _, synset := range order {
+		for _, word := range synset.words {
+			entry, ok := entries[strings.ToLower(word)]
+			if !ok {
+				entry = &Entry{Word: word}
+				entries[strings.ToLower(word)] = entry
+				ret = append(ret, entry)
+			}
+			addWordNetSense(entry, synset)
+		}
+	}
+
+	for _, entry := range ret {
+		entry.Definition = renderWordNet(entry)
+	}
+	return ret, nil
+}
+
+/* Parse a single synset line, returning its offset, the synset, and the
+ * keys of its hypernyms. */
+func parseWordNetLine(text string) (string, *wordNetSynset, []string, error) {
+	gloss := ""
+	if i := strings.Index(text, " | "); i >= 0 {
+		gloss = strings.TrimSpace(text[i+3:])
+		text = text[:i]
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) < 4 {
+		return "", nil, nil, fmt.Errorf("Synset too short")
+	}
+
+	synset := wordNetSynset{partOfSpeech: fields[2]}
+	if _, ok := wordNetPartsOfSpeech[synset.partOfSpeech]; !ok {
+		return "", nil, nil, fmt.Errorf("Unknown part of speech %q", fields[2])
+	}
+
+	/* The word count is in hex, for some reason. */
+	count, err := strconv.ParseInt(fields[3], 16, 32)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("Bad word count %q", fields[3])
+	}
+	position := 4
+	if len(fields) < position+int(count)*2+1 {
+		return "", nil, nil, fmt.Errorf("Synset too short for %d words", count)
+	}
+	for i := 0; i < int(count); i++ {
+		synset.words = append(synset.words, wordNetWord(fields[position]))
+		position += 2 /* skip the lex_id */
+	}
+
+	/* The pointer count isn't. */
+	count, err = strconv.ParseInt(fields[position], 10, 32)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("Bad pointer count %q", fields[position])
+	}
+	position++
+	if len(fields) < position+int(count)*4 {
+		return "", nil, nil, fmt.Errorf("Synset too short for %d pointers", count)
+	}
+
+	hypernyms := []string{}
+	for i := 0; i < int(count); i++ {
+		symbol := fields[position]
+		if symbol == "@" || symbol == "@i" {
+			hypernyms = append(hypernyms, wordNetKey(fields[position+2], fields[position+1]))
+		}
+		position += 4
+	}
+
+	synset.gloss, synset.examples = splitWordNetGloss(gloss)
+	return fields[0], &synset, hypernyms, nil
+}
+
+/* Turn a word as it is in the data files ("Canis_familiaris", "big(a)")
+ * into the headword. */
+func wordNetWord(word string) string {
+	if i := strings.Index(word, "("); i > 0 && strings.HasSuffix(word, ")") {
+		word = word[:i]
+	}
+	return strings.Replace(word, "_", " ", -1)
+}
+
+/* Split a gloss into the definition, and the "quoted" examples after it. */
+func splitWordNetGloss(gloss string) (string, []string) {
+	definitions := []string{}
+	examples := []string{}
+	for _, el := range strings.Split(gloss, "; ") {
+		el = strings.TrimSpace(el)
+		if strings.HasPrefix(el, "\"") {
+			examples = append(examples, strings.Trim(el, "\""))
+		} else if el != "" {
+			definitions = append(definitions, el)
+		}
+	}
+	return strings.Join(definitions, "; "), examples
+}
+
+/* Add the synset `synset` to `entry` as another sense. */
+func addWordNetSense(entry *Entry, synset *wordNetSynset) {
+	partOfSpeech := wordNetPartsOfSpeech[synset.partOfSpeech]
+	if !containsString(entry.PartsOfSpeech, partOfSpeech) {
+		entry.PartsOfSpeech = append(entry.PartsOfSpeech, partOfSpeech)
+	}
+
+	entry.Senses = append(entry.Senses, partOfSpeech+": "+synset.gloss)
+	entry.Examples = append(entry.Examples, synset.examples...)
+
+	for _, el := range synset.words {
+		if !strings.EqualFold(el, entry.Word) && !containsString(entry.Synonyms, el) {
+			entry.Synonyms = append(entry.Synonyms, el)
+		}
+	}
+	for _, el := range synset.hypernyms {
+		if !containsString(entry.Hypernyms, el) {
+			entry.Hypernyms = append(entry.Hypernyms, el)
+		}
+	}
+}
+
+/* Write a WordNet Entry out as text, the way `dict` users would expect. */
+func renderWordNet(entry *Entry) string {
+	lines := []string{}
+	for i, sense := range entry.Senses {
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, sense))
+	}
+	if len(entry.Examples) > 0 {
+		lines = append(lines, "", "Examples:")
+		for _, el := range entry.Examples {
+			lines = append(lines, "  \""+el+"\"")
+		}
+	}
+	if len(entry.Synonyms) > 0 {
+		lines = append(lines, "", "Synonyms: "+strings.Join(entry.Synonyms, ", "))
+	}
+	if len(entry.Hypernyms) > 0 {
+		lines = append(lines, "", "Hypernyms: "+strings.Join(entry.Hypernyms, ", "))
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+/* Check to see if `values` has `value` in it. */
+func containsString(values []string, value string) bool {
+	for _, el := range values {
+		if el == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+const wordNetData = `  1 This software and database is being provided to you, the LICENSEE, by
+  2 Princeton University under the following license.
+02084071 05 n 03 dog 0 domestic_dog 0 Canis_familiaris 0 002 @ 02083346 n 0000 ~ 01322604 n 0000 | a member of the genus Canis; "the dog barked all night"
+02083346 05 n 02 canine 0 canid 0 000 | any of various fissiped mammals
+01322604 05 n 01 puppy 0 001 @ 02084071 n 0000 | a young dog
+`
+
+func TestWordNetParser(t *testing.T) {
+	entries := parseString(t, "wordnet", nil, wordNetData)
+
+	if len(entries) != 6 {
+		t.Fatalf("Bad entry count out - didn't get 6")
+	}
+
+	dog := entries[0]
+	if dog.Word != "dog" || strings.Join(dog.PartsOfSpeech, ",") != "noun" {
+		t.Errorf("Bad first entry")
+	}
+
+	if len(dog.Senses) != 1 || dog.Senses[0] != "noun: a member of the genus Canis" {
+		t.Errorf("Bad senses %q", dog.Senses)
+	}
+
+	if len(dog.Examples) != 1 || dog.Examples[0] != "the dog barked all night" {
+		t.Errorf("Bad examples %q", dog.Examples)
+	}
+
+	if strings.Join(dog.Synonyms, ",") != "domestic dog,Canis familiaris" {
+		t.Errorf("Bad synonyms %q", dog.Synonyms)
+	}
+
+	if strings.Join(dog.Hypernyms, ",") != "canine,canid" {
+		t.Errorf("Bad hypernyms %q", dog.Hypernyms)
+	}
+
+	/* Pointing backwards works too. */
+	if strings.Join(entries[5].Hypernyms, ",") != "dog,domestic dog,Canis familiaris" {
+		t.Errorf("Bad hypernyms %q", entries[5].Hypernyms)
+	}
+}
+
+func TestWordNetParseError(t *testing.T) {
+	format, _ := Get("wordnet")
+	parser, _ := format.New(nil)
+	_, err := ReadAll(parser.Parse(strings.NewReader("02084071 05 n zz dog 0 000 | dog\n")))
+
+	if parseError, ok := err.(*ParseError); !ok || parseError.Line != 1 {
+		t.Errorf("Didn't get a parse error for line 1")
+	}
+}
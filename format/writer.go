@@ -0,0 +1,14 @@
+package format
+
+import (
+	"pault.ag/go/dictd/dictd"
+)
+
+/* Writer is the interface for writing Definitions out to a dictionary
+ * file format, one at a time. Close has to be called once everything's
+ * been written, since some formats can't finish until they've seen every
+ * Definition. */
+type Writer interface {
+	Write(definition *dictd.Definition) error
+	Close() error
+}
@@ -0,0 +1,83 @@
+package format
+
+import (
+	"io"
+	"strings"
+)
+
+func init() {
+	Register(Format{
+		Name:       "xdxf",
+		Extensions: []string{".xdxf"},
+		Detect: func(line string) bool {
+			return strings.HasPrefix(line, "<xdxf")
+		},
+		New: func(options Options) (Parser, error) {
+			return &XDXFParser{}, noOptions("xdxf", options)
+		},
+	})
+}
+
+/* XDXFParser reads XDXF dictionaries. Each <ar> (article) becomes an
+ * Entry:
+ *
+ *   <ar><k>dog</k><tr>dɒg</tr> <gr>n</gr>
+ *     <def><dtrn>собака</dtrn> <ex>a dog's life</ex></def>
+ *   </ar>
+ *
+ * Older ("visual") XDXF files don't bother with the <def>s, and just
+ * have the text of the article after the <k>, which we keep as it is. */
+type XDXFParser struct{}
+
+func (this *XDXFParser) Parse(reader io.Reader) Iterator {
+	return newXMLIterator(reader, "ar", xdxfEntry)
+}
+
+/* Turn an XDXF <ar> into an Entry. Careful: in XDXF <tr> is the
+ * transcription (pronunciation), and <dtrn> is the translation. */
+func xdxfEntry(node *xmlNode) *Entry {
+	entry := Entry{}
+
+	keys := node.Texts("k", "def")
+	if len(keys) > 0 {
+		entry.Word = keys[0]
+		entry.Synonyms = keys[1:]
+	}
+	if transcriptions := node.Texts("tr", "def"); len(transcriptions) > 0 {
+		entry.Pronunciation = transcriptions[0]
+	}
+	entry.PartsOfSpeech = node.Texts("gr", "def")
+	entry.Translations = node.Texts("dtrn")
+	entry.Examples = node.Texts("ex")
+	entry.References = node.Texts("kref")
+
+	entry.Senses = []string{}
+	for _, def := range node.Find("def") {
+		xdxfSenses(&entry, def)
+	}
+
+	if len(entry.Senses) == 0 {
+		text := node.Text("k", "tr", "gr", "ex")
+		if text != "" {
+			entry.Senses = append(entry.Senses, text)
+		}
+	}
+
+	entry.Definition = renderXMLEntry(&entry)
+	return &entry
+}
+
+/* Add the <def> `def` as a sense of `entry`, or if it's just a wrapper
+ * around more <def>s, each of those. */
+func xdxfSenses(entry *Entry, def *xmlNode) {
+	inner := def.Find("def")
+	if len(inner) == 0 {
+		if text := def.Text("ex", "gr"); text != "" {
+			entry.Senses = append(entry.Senses, text)
+		}
+		return
+	}
+	for _, el := range inner {
+		xdxfSenses(entry, el)
+	}
+}
@@ -0,0 +1,184 @@
+package format
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+/* xml.go - bits shared by the XML dictionary formats (TEI and XDXF).
+ *
+ * Both put each entry in its own element, and the files can be huge, so
+ * we stream through them with an xml.Decoder, and only build a tree for
+ * one entry at a time. The tree keeps text where it was relative to the
+ * elements around it, since XDXF (in particular) mixes them freely. */
+
+/* An element, or (with no name) a run of text. */
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string
+}
+
+/* Read the rest of the element started by `start` out of `decoder`. */
+func readXMLNode(decoder *xml.Decoder, start xml.StartElement) (*xmlNode, error) {
+	node := xmlNode{name: start.Name.Local, attrs: map[string]string{}}
+	for _, el := range start.Attr {
+		node.attrs[el.Name.Local] = el.Value
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			child, err := readXMLNode(decoder, token)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		case xml.CharData:
+			node.children = append(node.children, &xmlNode{text: string(token)})
+		case xml.EndElement:
+			return &node, nil
+		}
+	}
+}
+
+/* Get all of the text under this node, with the whitespace tidied up,
+ * leaving out anything inside elements named in `skip`. */
+func (this *xmlNode) Text(skip ...string) string {
+	parts := []string{}
+	var walk func(node *xmlNode)
+	walk = func(node *xmlNode) {
+		if node.name == "" {
+			parts = append(parts, node.text)
+			return
+		}
+		if containsString(skip, node.name) {
+			/* Keep words either side of it apart. */
+			parts = append(parts, " ")
+			return
+		}
+		for _, el := range node.children {
+			walk(el)
+		}
+	}
+	walk(this)
+	return strings.Join(strings.Fields(strings.Join(parts, "")), " ")
+}
+
+/* Find every element named `name` under this one, not looking inside any
+ * elements named in `stop` (or the ones we find). */
+func (this *xmlNode) Find(name string, stop ...string) []*xmlNode {
+	ret := []*xmlNode{}
+	for _, el := range this.children {
+		switch {
+		case el.name == "":
+		case el.name == name:
+			ret = append(ret, el)
+		case !containsString(stop, el.name):
+			ret = append(ret, el.Find(name, stop...)...)
+		}
+	}
+	return ret
+}
+
+/* Get the text of every element named `name` under this one, without
+ * blanks or repeats. */
+func (this *xmlNode) Texts(name string, stop ...string) []string {
+	ret := []string{}
+	for _, el := range this.Find(name, stop...) {
+		if text := el.Text(); text != "" && !containsString(ret, text) {
+			ret = append(ret, text)
+		}
+	}
+	return ret
+}
+
+/* Iterator over the elements named `element` in an XML file, which
+ * `convert` turns into Entries. */
+type xmlIterator struct {
+	decoder *xml.Decoder
+	element string
+	convert func(node *xmlNode) *Entry
+}
+
+func newXMLIterator(reader io.Reader, element string, convert func(node *xmlNode) *Entry) *xmlIterator {
+	decoder := xml.NewDecoder(reader)
+	/* Dictionaries are fond of &nbsp; and friends, and rarely bother
+	 * declaring them. */
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charsetReader
+	return &xmlIterator{
+		decoder: decoder,
+		element: element,
+		convert: convert,
+	}
+}
+
+/* Decode `input` from the encoding named `label` (like "ISO-8859-1" or
+ * "windows-1251", which XDXF files in particular are often in) into
+ * UTF-8, for the xml.Decoder. */
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	encoding, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, err
+	}
+	return transform.NewReader(input, encoding.NewDecoder()), nil
+}
+
+func (this *xmlIterator) Next() (*Entry, error) {
+	for {
+		token, err := this.decoder.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, this.error(err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != this.element {
+			continue
+		}
+
+		line, _ := this.decoder.InputPos()
+		node, err := readXMLNode(this.decoder, start)
+		if err != nil {
+			return nil, this.error(err)
+		}
+
+		entry := this.convert(node)
+		if entry.Word == "" {
+			return nil, &ParseError{Line: line, Message: fmt.Sprintf("<%s> with no headword", this.element)}
+		}
+		return entry, nil
+	}
+}
+
+/* Turn an error from the decoder into a ParseError, with the line it
+ * happened on. */
+func (this *xmlIterator) error(err error) error {
+	if syntaxError, ok := err.(*xml.SyntaxError); ok {
+		return &ParseError{Line: syntaxError.Line, Message: syntaxError.Msg}
+	}
+	line, _ := this.decoder.InputPos()
+	return &ParseError{Line: line, Message: err.Error()}
+}
+
+/* Write an Entry from one of the XML formats out as text, the same way
+ * the server would from its structured fields. */
+func renderXMLEntry(entry *Entry) string {
+	definition := entry.AsDefinition()
+	definition.Definition = ""
+	definition.Source = ""
+	return strings.Replace(definition.Body(), "\n", "\r\n", -1)
+}
@@ -0,0 +1,105 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTEIParser(t *testing.T) {
+	entries := parseString(t, "tei", nil, `<?xml version="1.0" encoding="UTF-8"?>
+<TEI xmlns="http://www.tei-c.org/ns/1.0"><text><body>
+<entry>
+  <form><orth>dog</orth><pron>dɒg</pron></form>
+  <gramGrp><pos>n</pos></gramGrp>
+  <sense n="1">
+    <usg type="dom">zool.</usg>
+    <cit type="trans"><quote>Hund</quote></cit>
+    <cit type="example"><quote>the dog barks</quote></cit>
+  </sense>
+  <sense n="2"><cit type="trans"><quote>Kerl</quote></cit><xr><ref>fellow</ref></xr></sense>
+</entry>
+<entry><form><orth>cat</orth></form><trans><tr>Katze</tr></trans></entry>
+</body></text></TEI>`)
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+
+	dog := entries[0]
+	if dog.Word != "dog" || dog.Pronunciation != "dɒg" || strings.Join(dog.PartsOfSpeech, ",") != "n" {
+		t.Errorf("Bad headword, pronunciation or part of speech")
+	}
+
+	if strings.Join(dog.Senses, "|") != "(zool.) Hund|Kerl" {
+		t.Errorf("Bad senses %q", dog.Senses)
+	}
+
+	if strings.Join(dog.Translations, ",") != "Hund,Kerl" {
+		t.Errorf("Bad translations %q", dog.Translations)
+	}
+
+	if strings.Join(dog.Examples, ",") != "the dog barks" || strings.Join(dog.References, ",") != "fellow" {
+		t.Errorf("Bad examples or references")
+	}
+
+	if entries[1].Word != "cat" || strings.Join(entries[1].Translations, ",") != "Katze" {
+		t.Errorf("Bad P4 entry")
+	}
+}
+
+func TestXDXFParser(t *testing.T) {
+	entries := parseString(t, "xdxf", nil, `<?xml version="1.0" encoding="UTF-8"?>
+<xdxf lang_from="ENG" lang_to="RUS" format="logical"><lexicon>
+<ar><k>dog</k><tr>dɒg</tr> <gr>n</gr>
+  <def><def><dtrn>собака</dtrn> <ex>a dog&apos;s life</ex></def><def><dtrn>пёс</dtrn>, see <kref>hound</kref></def></def>
+</ar>
+<ar><k>cat</k>
+  кошка&nbsp;(animal)
+</ar>
+</lexicon></xdxf>`)
+
+	if len(entries) != 2 {
+		t.Fatalf("Bad entry count out - didn't get 2")
+	}
+
+	dog := entries[0]
+	if dog.Pronunciation != "dɒg" || strings.Join(dog.PartsOfSpeech, ",") != "n" {
+		t.Errorf("Bad pronunciation or part of speech")
+	}
+
+	if strings.Join(dog.Senses, "|") != "собака|пёс, see hound" {
+		t.Errorf("Bad senses %q", dog.Senses)
+	}
+
+	if strings.Join(dog.Translations, ",") != "собака,пёс" || strings.Join(dog.References, ",") != "hound" {
+		t.Errorf("Bad translations or references")
+	}
+
+	if strings.Join(entries[1].Senses, "|") != "кошка (animal)" {
+		t.Errorf("Bad visual sense %q", entries[1].Senses)
+	}
+}
+
+func TestXDXFCharset(t *testing.T) {
+	entries := parseString(t, "xdxf", nil, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n"+
+		"<xdxf><lexicon><ar><k>caf\xe9</k>coffee</ar></lexicon></xdxf>")
+	if len(entries) != 1 || entries[0].Word != "café" {
+		t.Errorf("Bad Latin-1 headword")
+	}
+
+	entries = parseString(t, "xdxf", nil, "<?xml version=\"1.0\" encoding=\"windows-1251\"?>\n"+
+		"<xdxf><lexicon><ar><k>dog</k>\xf1\xee\xe1\xe0\xea\xe0</ar></lexicon></xdxf>")
+	if len(entries) != 1 || strings.Join(entries[0].Senses, "|") != "собака" {
+		t.Errorf("Bad windows-1251 sense")
+	}
+}
+
+func TestXMLParseError(t *testing.T) {
+	format, _ := Get("xdxf")
+	parser, _ := format.New(nil)
+	_, err := ReadAll(parser.Parse(strings.NewReader("<xdxf>\n<ar><k>dog</k>\n</xdxf>")))
+
+	if parseError, ok := err.(*ParseError); !ok || parseError.Line != 3 {
+		t.Errorf("Didn't get a parse error for line 3: %v", err)
+	}
+}
@@ -15,10 +15,27 @@ type Configuration struct {
 	Name string
 	Info string
 
+	/* Columns to wrap definitions to; 0 for the default, or -1 to not
+	 * wrap them at all. */
+	Width int
+
+	/* Suggest words when DEFINE finds nothing, with the strategies in
+	 * SuggestStrategies (or the default ones), and at most SuggestLimit
+	 * of them (0 for the default). */
+	Suggest           bool
+	SuggestStrategies []string
+	SuggestLimit      int
+
+	/* A file to append a JSON line to for every DEFINE and MATCH, for
+	 * `dictd-admin misses` to read. */
+	QueryLog string
+
 	Databases []struct {
-		Name string
-		Path string
-		Desc string
+		Name         string
+		Path         string
+		Desc         string
+		MaxResults   int
+		StemFallback bool
 	}
 }
 
@@ -47,6 +64,26 @@ func main() {
 	}
 
 	server := dictd.NewServer(config.Name)
+	if config.Width != 0 {
+		server.Width = config.Width
+	}
+	if config.Suggest {
+		server.SuggestStrategies = dictd.DefaultSuggestStrategies
+		if len(config.SuggestStrategies) > 0 {
+			server.SuggestStrategies = config.SuggestStrategies
+		}
+	}
+	if config.SuggestLimit != 0 {
+		server.SuggestLimit = config.SuggestLimit
+	}
+	if config.QueryLog != "" {
+		file, err := os.OpenFile(config.QueryLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		server.QueryLog = dictd.NewQueryLog(file)
+	}
 
 	for _, dbConfig := range config.Databases {
 		db, err := database.NewLevelDBDatabase(
@@ -56,6 +93,8 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+		db.SetMaxResults(dbConfig.MaxResults)
+		db.SetStemFallback(dbConfig.StemFallback)
 		server.RegisterDatabase(db, dbConfig.Name, true)
 	}
 
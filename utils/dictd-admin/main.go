@@ -0,0 +1,820 @@
+package main
+
+/* dictd-admin - look after the LevelDB databases go-dictd serves.
+ *
+ *   dictd-admin import [flags] <db> <file or directory>...
+ *   dictd-admin export [flags] <db> <out>
+ *   dictd-admin stats <db>
+ *   dictd-admin verify [flags] <db>
+ *   dictd-admin reindex [flags] <db>
+ *   dictd-admin lookup <db> <word>...
+ *   dictd-admin match [flags] <db> <query>...
+ *   dictd-admin delete [flags] <db> <word>...
+ *   dictd-admin misses [flags] <query log>...
+ *
+ * `dictd-admin <command> -h` has the flags for each. A WordNet database
+ * wants all of the data files read together, so pointers between them
+ * can be followed:
+ *
+ *   cat data.noun data.verb data.adj data.adv |
+ *       dictd-admin import -format wordnet wordnet.db -
+ *
+ * MATCH results are ranked partly by how often each word is used, which
+ * import takes from a -frequencies file of "word count" lines (like a
+ * corpus word list), with or without any dictionary files:
+ *
+ *   dictd-admin import -frequencies counts.txt jargon.db */
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pault.ag/go/dictd/database"
+	"pault.ag/go/dictd/dictd"
+	"pault.ag/go/dictd/format"
+)
+
+/* A subcommand. `run` gets the arguments after the command's name. */
+type command struct {
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"import": {
+			"[flags] <db> [file or directory]...",
+			"load dictionary files (or word frequencies) into a db",
+			importCommand,
+		},
+		"export": {
+			"[flags] <db> <out>",
+			"write a db out as a dictionary file",
+			exportCommand,
+		},
+		"stats": {
+			"<db>",
+			"show how many keys are in each of a db's namespaces",
+			statsCommand,
+		},
+		"verify": {
+			"[flags] <db>",
+			"check a db's indexes agree with its words",
+			verifyCommand,
+		},
+		"reindex": {
+			"[flags] <db>",
+			"rebuild a db's indexes from its words",
+			reindexCommand,
+		},
+		"lookup": {
+			"<db> <word>...",
+			"define words, like DEFINE does",
+			lookupCommand,
+		},
+		"match": {
+			"[flags] <db> <query>...",
+			"find words, like MATCH does",
+			matchCommand,
+		},
+		"delete": {
+			"[flags] <db> <word>...",
+			"remove words from a db",
+			deleteCommand,
+		},
+		"misses": {
+			"[flags] <query log>...",
+			"list the words people looked up and didn't find",
+			missesCommand,
+		},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags] <db> ...\n\nCommands:\n", os.Args[0])
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].description)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", os.Args[0], os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+/* Set up the flags for the command `name`, with a usage message that
+ * knows what the positional arguments are. */
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n", os.Args[0], name, commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+/* Parse `args` into `flags`, check we've got at least `min` positional
+ * arguments (the first of which is the db), and open the db, which has to
+ * be there already; LevelDB would happily make an empty one for a typo. */
+func openDatabase(flags *flag.FlagSet, args []string, min int) (*database.LevelDBDatabase, error) {
+	return openOrCreateDatabase(flags, args, min, false)
+}
+
+/* Like openDatabase, but if `create` is set, a missing db is made. */
+func openOrCreateDatabase(flags *flag.FlagSet, args []string, min int, create bool) (*database.LevelDBDatabase, error) {
+	flags.Parse(args)
+	if flags.NArg() < min {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !create {
+		if _, err := os.Stat(flags.Arg(0)); err != nil {
+			return nil, err
+		}
+	}
+	return database.NewLevelDBDatabase(flags.Arg(0), "")
+}
+
+/* Turn a comma separated list of namespaces into what Verify and Reindex
+ * want, which is an empty list for all of them. */
+func splitNamespaces(namespaces string) []string {
+	if namespaces == "" {
+		return []string{}
+	}
+	return strings.Split(namespaces, ",")
+}
+
+/* optionsFlag collects repeated -option key=value flags. */
+type optionsFlag map[string]string
+
+func (this optionsFlag) String() string {
+	pairs := []string{}
+	for key, value := range this {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (this optionsFlag) Set(value string) error {
+	pair := strings.SplitN(value, "=", 2)
+	if len(pair) != 2 {
+		return fmt.Errorf("Options look like key=value, not %q", value)
+	}
+	this[pair[0]] = pair[1]
+	return nil
+}
+
+/* Expand any directories in `paths` into the files under them, either
+ * the ones with names matching the glob `match`, or if that's empty, the
+ * ones with an extension we know. Hidden directories (like .git) are
+ * skipped. */
+func inputPaths(paths []string, match string) ([]string, error) {
+	ret := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if path == "-" || (err == nil && !info.IsDir()) {
+			ret = append(ret, path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := info.Name()
+			if info.IsDir() {
+				if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if match != "" {
+				ok, err := filepath.Match(match, name)
+				if err != nil {
+					return err
+				}
+				if ok {
+					ret = append(ret, path)
+				}
+			} else if _, ok := format.ForExtension(path); ok {
+				ret = append(ret, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+/* Open the dictionary file at `path`, or, if that's "-", stdin, which
+ * spoolStdin has already copied to the file at `stdin`. Formats that read
+ * a whole set of files at once (like WordNet's data files) can be fed
+ * them all with cat, that way. */
+func openInput(path string, stdin string, name string, options format.Options) (format.Iterator, io.Closer, error) {
+	if path != "-" {
+		return format.Open(path, name, options)
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("Give me a -format to read stdin as")
+	}
+	return format.Open(stdin, name, options)
+}
+
+/* Copy stdin into a temporary file, so it can be read more than once,
+ * and get its path. The caller removes it. */
+func spoolStdin() (string, error) {
+	file, err := ioutil.TempFile("", "dictd-admin")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, os.Stdin); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+/* Call `fn` with each Entry in the dictionary file at `path` (see
+ * openInput) in turn, so only one is held in memory at a time. */
+func eachEntry(path string, stdin string, name string, options format.Options, fn func(*format.Entry) error) error {
+	iterator, file, err := openInput(path, stdin, name, options)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for {
+		entry, err := iterator.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+/* Call `fn` with each word and count in the frequency list at `path`,
+ * which has a word (which may have spaces in it) and how often it's used
+ * on each line. Blank lines, and lines starting with "#", are skipped. */
+func eachFrequency(path string, fn func(word string, count int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		split := strings.LastIndexAny(text, " \t")
+		if split < 0 {
+			return fmt.Errorf("line %d: No count for %q", line, text)
+		}
+		count, err := strconv.Atoi(text[split+1:])
+		if err != nil || count < 0 {
+			return fmt.Errorf("line %d: Bad count %q", line, text[split+1:])
+		}
+		if err := fn(strings.TrimSpace(text[:split]), count); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func importCommand(args []string) error {
+	flags := newFlagSet("import")
+	formatName := flags.String("format", "", "format of the files, one of "+
+		strings.Join(format.Names(), ", ")+" (default: guess from each file)")
+	options := optionsFlag{}
+	flags.Var(options, "option", "format specific key=value option (can be repeated)")
+	match := flags.String("match", "", "when importing a directory, only read files named like this (like GLOSSARY.md)")
+	appendMode := flags.Bool("append", false, "add to the db, redefining any words that are already there (default)")
+	replaceMode := flags.Bool("replace", false, "throw away every word in the db first")
+	updateMode := flags.Bool("update", false, "only redefine words that are already in the db")
+	dryRun := flags.Bool("dry-run", false, "read the files and say what would change, without writing anything")
+	progress := flags.Int("progress", 10000, "report progress every this many words (0 for never)")
+	stripDiacritics := flags.Bool("strip-diacritics", false, "ignore diacritics when looking up words in this db")
+	language := flags.String("language", "", "language the words are in, for stemming (default english)")
+	frequencies := flags.String("frequencies", "", "file of \"word count\" lines, saying how often words are used, for ranking MATCH results")
+
+	db, err := openOrCreateDatabase(flags, args, 1, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if flags.NArg() < 2 && *frequencies == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	modes := 0
+	for _, el := range []bool{*appendMode, *replaceMode, *updateMode} {
+		if el {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("Only one of -append, -replace and -update, please")
+	}
+
+	paths, err := inputPaths(flags.Args()[1:], *match)
+	if err != nil {
+		return err
+	}
+
+	stdin := ""
+	for _, path := range paths {
+		if path != "-" || stdin != "" {
+			continue
+		}
+		if *formatName == "" {
+			return fmt.Errorf("Give me a -format to read stdin as")
+		}
+		if stdin, err = spoolStdin(); err != nil {
+			return err
+		}
+		defer os.Remove(stdin)
+	}
+
+	/* Parse everything once before writing anything, so a bad file
+	 * doesn't leave us half done (or, with -replace, with nothing at
+	 * all). Entries are thrown away as they're read, and read again
+	 * below, rather than holding the whole lot in memory. */
+	for _, path := range paths {
+		count := 0
+		err := eachEntry(path, stdin, *formatName, format.Options(options), func(*format.Entry) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "Read %d words from %s\n", count, path)
+	}
+	if *frequencies != "" {
+		count := 0
+		err := eachFrequency(*frequencies, func(string, int) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %s", *frequencies, err)
+		}
+		fmt.Fprintf(os.Stderr, "Read %d frequencies from %s\n", count, *frequencies)
+	}
+
+	loader := database.NewBulkLoader(db)
+	report := func(verb string, count int) {
+		if *progress > 0 && count > 0 && count%*progress == 0 {
+			fmt.Fprintf(os.Stderr, "%s %d words\n", verb, count)
+		}
+	}
+
+	/* Keys are built with these, so changing them when there are words
+	 * in the db already would leave some under the old keys and some
+	 * under the new ones. */
+	renormalizing := (*stripDiacritics && !db.StripDiacritics()) ||
+		(*language != "" && *language != db.Language())
+	if renormalizing && !*replaceMode && !db.Empty() {
+		return fmt.Errorf("Can't change -strip-diacritics or -language on a db with words in it without -replace")
+	}
+
+	/* The old words have to go by the keys they're stored under, before
+	 * the settings change under them. */
+	removed := 0
+	if *replaceMode {
+		if *dryRun {
+			stats, err := db.Stats()
+			if err != nil {
+				return err
+			}
+			removed = stats["words"]
+		} else if removed, err = loader.DeleteAll(); err != nil {
+			return err
+		}
+	}
+
+	if !*dryRun {
+		if *stripDiacritics {
+			db.SetStripDiacritics(true)
+		}
+		if *language != "" {
+			db.SetLanguage(*language)
+		}
+	}
+
+	/* Define (without the stem fallback) is an exact lookup of the key,
+	 * which gets every headword that normalizes the same; writing only
+	 * replaces the one spelled exactly the same. */
+	defined := func(word string) bool {
+		if *replaceMode {
+			return false
+		}
+		for _, el := range db.Define("", word) {
+			if el.Word == word {
+				return true
+			}
+		}
+		return false
+	}
+
+	added, redefined, skipped := 0, 0, 0
+	for _, path := range paths {
+		err := eachEntry(path, stdin, *formatName, format.Options(options), func(entry *format.Entry) error {
+			exists := defined(entry.Word)
+			if *updateMode && !exists {
+				skipped++
+				return nil
+			}
+			if !*dryRun {
+				if err := loader.WriteStructuredDefinition(entry.AsDefinition()); err != nil {
+					return err
+				}
+			}
+			if exists {
+				redefined++
+			} else {
+				added++
+			}
+			report("Imported", added+redefined)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	if *frequencies != "" && !*dryRun {
+		err := eachFrequency(*frequencies, loader.WriteFrequency)
+		if err != nil {
+			return fmt.Errorf("%s: %s", *frequencies, err)
+		}
+	}
+
+	if !*dryRun {
+		if err := loader.Flush(); err != nil {
+			return err
+		}
+	}
+
+	verb := "Done"
+	if *dryRun {
+		verb = "Dry run, nothing written"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d added, %d redefined, %d removed, %d skipped\n",
+		verb, added, redefined, removed, skipped)
+	return nil
+}
+
+/* Which format to export in, going by the output path's extension. */
+var exportFormats = map[string]string{
+	".jargon": "jargon",
+	".jsonl":  "jsonl",
+	".index":  "dictd",
+	".dict":   "dictd",
+}
+
+func exportCommand(args []string) error {
+	flags := newFlagSet("export")
+	formatName := flags.String("format", "", "jargon, dictd or jsonl (default: guess from the output path)")
+
+	db, err := openDatabase(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	path := flags.Arg(1)
+	name := *formatName
+	if name == "" {
+		name = exportFormats[strings.ToLower(filepath.Ext(path))]
+	}
+	if name == "" {
+		name = "jargon"
+	}
+
+	/* The dictd format is two files, so we want the name without the
+	 * extension, and add both. */
+	if name == "dictd" {
+		path = strings.TrimSuffix(strings.TrimSuffix(path, ".index"), ".dict")
+	}
+
+	return exportDatabase(db, name, path)
+}
+
+/* Write every definition in `db` out to `path` in the format `name`. The
+ * dictd format is two files, so `path` gets .index and .dict tacked on. */
+func exportDatabase(db *database.LevelDBDatabase, name string, path string) error {
+	var writer format.Writer
+	files := []*os.File{}
+
+	create := func(path string) (*os.File, error) {
+		fd, err := os.Create(path)
+		if err == nil {
+			files = append(files, fd)
+		}
+		return fd, err
+	}
+	defer func() {
+		for _, fd := range files {
+			fd.Close()
+		}
+	}()
+
+	switch name {
+	case "jargon", "jsonl":
+		fd, err := create(path)
+		if err != nil {
+			return err
+		}
+		if name == "jargon" {
+			writer = format.NewJargonWriter(fd)
+		} else {
+			writer = format.NewJSONLinesWriter(fd)
+		}
+	case "dictd":
+		index, err := create(path + ".index")
+		if err != nil {
+			return err
+		}
+		dict, err := create(path + ".dict")
+		if err != nil {
+			return err
+		}
+		writer = format.NewDictdWriter(index, dict)
+	default:
+		return fmt.Errorf("Unknown export format %q", name)
+	}
+
+	count := 0
+	err := db.ForEachDefinition("", func(definition *dictd.Definition) error {
+		count++
+		return writer.Write(definition)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	for _, fd := range files {
+		if err := fd.Sync(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d words\n", count)
+	return nil
+}
+
+func statsCommand(args []string) error {
+	flags := newFlagSet("stats")
+	db, err := openDatabase(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := db.Stats()
+	if err != nil {
+		return err
+	}
+
+	namespaces := []string{}
+	for namespace := range stats {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		fmt.Printf("%-12s %d\n", namespace, stats[namespace])
+	}
+	return nil
+}
+
+func verifyCommand(args []string) error {
+	flags := newFlagSet("verify")
+	namespaces := flags.String("namespaces", "", "comma separated index namespaces to check (default all)")
+
+	db, err := openDatabase(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := db.Verify(splitNamespaces(*namespaces))
+	if err != nil {
+		return err
+	}
+	for _, el := range report.Orphans {
+		fmt.Printf("Orphaned posting: %s\n", el)
+	}
+	for _, el := range report.Missing {
+		fmt.Printf("Missing posting: %s\n", el)
+	}
+	fmt.Printf("%d words, %d orphaned postings, %d missing postings\n",
+		report.Words, len(report.Orphans), len(report.Missing))
+
+	if !report.OK() {
+		return fmt.Errorf("The indexes need rebuilding, try reindex")
+	}
+	return nil
+}
+
+func reindexCommand(args []string) error {
+	flags := newFlagSet("reindex")
+	namespaces := flags.String("namespaces", "", "comma separated index namespaces to rebuild (default all)")
+	compact := flags.Bool("compact", true, "compact the db once we're done")
+
+	db, err := openDatabase(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Reindex(splitNamespaces(*namespaces)); err != nil {
+		return err
+	}
+	if *compact {
+		return db.Compact()
+	}
+	return nil
+}
+
+func lookupCommand(args []string) error {
+	flags := newFlagSet("lookup")
+	stemFallback := flags.Bool("stem-fallback", false, "try other inflections of words that aren't defined")
+
+	db, err := openDatabase(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetStemFallback(*stemFallback)
+
+	missing := 0
+	for _, word := range flags.Args()[1:] {
+		defs := db.Define("", word)
+		if len(defs) == 0 {
+			fmt.Fprintf(os.Stderr, "No definitions for %s\n", word)
+			missing++
+		}
+		for _, def := range defs {
+			text := strings.Replace(def.Text(), "\r\n", "\n", -1)
+			fmt.Printf("%s\n  %s\n\n", def.Word,
+				strings.Replace(text, "\n", "\n  ", -1))
+		}
+	}
+
+	if missing > 0 {
+		return fmt.Errorf("%d words weren't found", missing)
+	}
+	return nil
+}
+
+func matchCommand(args []string) error {
+	flags := newFlagSet("match")
+	strategy := flags.String("strategy", ".", "strategy to match with (see -list)")
+	list := flags.Bool("list", false, "list the strategies, rather than matching")
+	maxResults := flags.Int("max-results", 0, "most matches to show per query (0 for all of them)")
+
+	flags.Parse(args)
+	if *list {
+		strategies := (&database.LevelDBDatabase{}).Strategies("")
+		names := []string{}
+		for name := range strategies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-12s %s\n", name, strategies[name])
+		}
+		return nil
+	}
+
+	db, err := openDatabase(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxResults(*maxResults)
+
+	for _, query := range flags.Args()[1:] {
+		for _, def := range db.Match("", query, *strategy) {
+			fmt.Printf("%s\t%s\n", query, def.Word)
+		}
+	}
+	return nil
+}
+
+func deleteCommand(args []string) error {
+	flags := newFlagSet("delete")
+	dryRun := flags.Bool("dry-run", false, "say what would be deleted, without deleting it")
+
+	db, err := openDatabase(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	missing := 0
+	for _, word := range flags.Args()[1:] {
+		if len(db.Define("", word)) == 0 {
+			fmt.Fprintf(os.Stderr, "%s isn't defined\n", word)
+			missing++
+			continue
+		}
+		if *dryRun {
+			fmt.Printf("Would delete %s\n", word)
+			continue
+		}
+		if err := db.DeleteDefinition(word); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", word)
+	}
+
+	if missing > 0 {
+		return fmt.Errorf("%d words weren't found", missing)
+	}
+	return nil
+}
+
+func missesCommand(args []string) error {
+	flags := newFlagSet("misses")
+	min := flags.Int("min", 1, "only list words missed at least this many times")
+	top := flags.Int("top", 0, "list at most this many words (0 for all of them)")
+
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	readers := []io.Reader{}
+	for _, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	misses, err := dictd.Misses(io.MultiReader(readers...))
+	if err != nil {
+		return err
+	}
+	for i, el := range misses {
+		if el.Count < *min || (*top > 0 && i >= *top) {
+			break
+		}
+		fmt.Printf("%6d  %-24s %s  %s\n", el.Count, el.Word,
+			el.Last.Format("2006-01-02"), strings.Join(el.Databases, ","))
+	}
+	return nil
+}